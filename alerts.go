@@ -0,0 +1,834 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	alertAbove = "above"
+	alertBelow = "below"
+
+	alertFiring   = "firing"
+	alertResolved = "resolved"
+
+	defaultAlertSeverity = "warning"
+
+	// warningMetric is the level of the most severe official warning in
+	// force (see WeatherAlert.level), 0 when there is none. Rules
+	// on it relay the provider's warnings.
+	warningMetric = "warning_level"
+)
+
+var alertSeverities = []string{"info", "warning", "critical"}
+
+// AlertRule is a threshold on one metric at one location, for example
+// "temp below 0 within 48h". Thresholds are in the canonical metric units
+// (°C, km/h, hPa, mm, %).
+type AlertRule struct {
+	Name      string  `json:"name"`
+	Location  string  `json:"location,omitempty"` // default_city when empty
+	Metric    string  `json:"metric"`
+	Operator  string  `json:"operator"` // above or below
+	Threshold float64 `json:"threshold"`
+
+	// Within also checks the forecast this far ahead (a Go duration such
+	// as "48h"); without it only current conditions are checked.
+	Within string `json:"within,omitempty"`
+
+	// Hysteresis is how far back past the threshold the value must go
+	// before a firing alert resolves, so values hovering around the
+	// threshold do not flap.
+	Hysteresis float64 `json:"hysteresis,omitempty"`
+
+	Severity string `json:"severity,omitempty"` // info, warning (default) or critical
+}
+
+// alertMetric is a quantity rules can test. current is nil for metrics only
+// forecasts have and hourly is nil for those they lack; either returns NaN
+// when a reading or hour lacks the value. unit is nil for dimensionless
+// metrics.
+type alertMetric struct {
+	current func(WeatherData) float64
+	hourly  func(ForecastHour) float64
+	format  func(UnitSystem, float64) string
+	unit    func(UnitSystem) string
+}
+
+// header labels a column of the metric called name, with its unit, e.g.
+// "Heat index (°F)".
+func (m alertMetric) header(name string, units UnitSystem) string {
+	label := strings.ToUpper(name[:1]) + strings.ReplaceAll(name[1:], "_", " ")
+	if m.unit == nil {
+		return label
+	}
+	return fmt.Sprintf("%s (%s)", label, m.unit(units))
+}
+
+func formatPercent(_ UnitSystem, value float64) string {
+	return fmt.Sprintf("%.0f%%", value)
+}
+
+func formatWarningLevel(_ UnitSystem, value float64) string {
+	if level := int(value); level > 0 && level <= len(warningSeverities) {
+		return strings.ToLower(warningSeverities[level-1])
+	}
+	return "none"
+}
+
+func formatHumidex(_ UnitSystem, value float64) string {
+	return fmt.Sprintf("%.0f", value)
+}
+
+func formatAbsoluteHumidity(_ UnitSystem, value float64) string {
+	return fmt.Sprintf("%.1f g/m³", value)
+}
+
+func unitPercent(UnitSystem) string          { return "%" }
+func unitAbsoluteHumidity(UnitSystem) string { return "g/m³" }
+
+// derivedMetric tests one of the DerivedMetrics of readings and forecast
+// hours; value returns nil when the metric is missing.
+func derivedMetric(value func(DerivedMetrics) *float64, format func(UnitSystem, float64) string,
+	unit func(UnitSystem) string) alertMetric {
+	orNaN := func(d DerivedMetrics) float64 {
+		if v := value(d); v != nil {
+			return *v
+		}
+		return math.NaN()
+	}
+	return alertMetric{
+		func(w WeatherData) float64 { return orNaN(w.Derived()) },
+		func(h ForecastHour) float64 { return orNaN(h.Derived()) },
+		format,
+		unit,
+	}
+}
+
+var alertMetrics = map[string]alertMetric{
+	"temp": {
+		func(w WeatherData) float64 { return w.TempC },
+		func(h ForecastHour) float64 { return h.TempC },
+		UnitSystem.FormatTemp,
+		UnitSystem.TempSymbol,
+	},
+	"feelslike": {
+		func(w WeatherData) float64 { return w.FeelsLikeC },
+		func(h ForecastHour) float64 { return h.FeelsLikeC },
+		UnitSystem.FormatTemp,
+		UnitSystem.TempSymbol,
+	},
+	"humidity": {
+		func(w WeatherData) float64 { return float64(w.Humidity) },
+		func(h ForecastHour) float64 { return float64(h.Humidity) },
+		formatPercent,
+		unitPercent,
+	},
+	"wind": {
+		func(w WeatherData) float64 { return w.WindKph },
+		func(h ForecastHour) float64 { return h.WindKph },
+		UnitSystem.FormatSpeed,
+		UnitSystem.SpeedSymbol,
+	},
+	"pressure": {
+		func(w WeatherData) float64 { return w.PressureMb },
+		nil,
+		UnitSystem.FormatPressure,
+		UnitSystem.PressureSymbol,
+	},
+	"precip": {
+		func(w WeatherData) float64 { return w.PrecipMm },
+		func(h ForecastHour) float64 { return h.PrecipMm },
+		UnitSystem.FormatPrecip,
+		UnitSystem.PrecipSymbol,
+	},
+	"chance_of_rain": {
+		nil,
+		func(h ForecastHour) float64 { return float64(h.ChanceOfRain) },
+		formatPercent,
+		unitPercent,
+	},
+	"dewpoint": derivedMetric(func(d DerivedMetrics) *float64 { return d.DewPointC },
+		UnitSystem.FormatTemp, UnitSystem.TempSymbol),
+	"heat_index": derivedMetric(func(d DerivedMetrics) *float64 { return d.HeatIndexC },
+		UnitSystem.FormatTemp, UnitSystem.TempSymbol),
+	"wind_chill": derivedMetric(func(d DerivedMetrics) *float64 { return &d.WindChillC },
+		UnitSystem.FormatTemp, UnitSystem.TempSymbol),
+	"humidex": derivedMetric(func(d DerivedMetrics) *float64 { return d.Humidex },
+		formatHumidex, nil),
+	"wetbulb": derivedMetric(func(d DerivedMetrics) *float64 { return d.WetBulbC },
+		UnitSystem.FormatTemp, UnitSystem.TempSymbol),
+	"absolute_humidity": derivedMetric(func(d DerivedMetrics) *float64 { return d.AbsoluteHumidityGm3 },
+		formatAbsoluteHumidity, unitAbsoluteHumidity),
+	// Official warnings are neither readings nor hourly values; see
+	// worstWarning.
+	warningMetric: {nil, nil, formatWarningLevel, nil},
+}
+
+// within returns the rule's forecast horizon; validateConfig has checked it.
+func (r AlertRule) within() time.Duration {
+	d, _ := time.ParseDuration(r.Within)
+	return d
+}
+
+func (r AlertRule) severity() string {
+	if r.Severity == "" {
+		return defaultAlertSeverity
+	}
+	return r.Severity
+}
+
+// breached reports whether value is past the threshold.
+func (r AlertRule) breached(value float64) bool {
+	if r.Operator == alertAbove {
+		return value > r.Threshold
+	}
+	return value < r.Threshold
+}
+
+// cleared reports whether value is back past the threshold by at least the
+// hysteresis.
+func (r AlertRule) cleared(value float64) bool {
+	if r.Operator == alertAbove {
+		return value <= r.Threshold-r.Hysteresis
+	}
+	return value >= r.Threshold+r.Hysteresis
+}
+
+// worst returns the value of the rule's metric that comes closest to, or
+// goes furthest past, the threshold: now and, if the rule has a horizon,
+// every forecast hour up to it, skipping any that lack the metric. ok is
+// false when there is no such value.
+func (r AlertRule) worst(forecast *Forecast, now time.Time) (value float64, at time.Time, ok bool) {
+	if r.Metric == warningMetric {
+		warning := r.worstWarning(forecast, now)
+		if warning == nil {
+			return 0, now, true
+		}
+		at = now
+		if warning.Effective.After(now) {
+			at = warning.Effective
+		}
+		return float64(warning.level()), at, true
+	}
+
+	metric := alertMetrics[r.Metric]
+	consider := func(v float64, t time.Time) {
+		if math.IsNaN(v) {
+			return
+		}
+		better := v > value
+		if r.Operator == alertBelow {
+			better = v < value
+		}
+		if !ok || better {
+			value, at, ok = v, t, true
+		}
+	}
+
+	if metric.current != nil {
+		at := forecast.Current.Timestamp
+		if at.IsZero() {
+			at = now
+		}
+		consider(metric.current(forecast.Current), at)
+	}
+	if horizon := r.within(); horizon > 0 && metric.hourly != nil {
+		end := now.Add(horizon)
+		for _, day := range forecast.Days {
+			for _, hour := range day.Hours {
+				if hour.Time.After(now) && !hour.Time.After(end) {
+					consider(metric.hourly(hour), hour.Time)
+				}
+			}
+		}
+	}
+	return value, at, ok
+}
+
+// worstWarning returns the most severe official warning in force now or,
+// if the rule has a horizon, taking effect before it ends.
+func (r AlertRule) worstWarning(forecast *Forecast, now time.Time) *WeatherAlert {
+	end := now.Add(r.within())
+	var worst *WeatherAlert
+	for i, warning := range forecast.Alerts {
+		if !warning.inForce(now) || warning.Effective.After(end) {
+			continue
+		}
+		if worst == nil || warning.level() > worst.level() {
+			worst = &forecast.Alerts[i]
+		}
+	}
+	return worst
+}
+
+// validateAlertRules reports problems with the alerts config key.
+func validateAlertRules(rules []AlertRule, invalid func(key, format string, args ...any)) {
+	seen := make(map[string]bool)
+	for i, rule := range rules {
+		key := fmt.Sprintf("alerts[%d]", i)
+		if rule.Name == "" {
+			invalid(key+".name", "is required")
+		} else if seen[strings.ToLower(rule.Name)] {
+			invalid(key+".name", "%q is used more than once", rule.Name)
+		}
+		seen[strings.ToLower(rule.Name)] = true
+
+		metric, ok := alertMetrics[rule.Metric]
+		if !ok {
+			invalid(key+".metric", "unknown metric %q; use one of %s", rule.Metric, strings.Join(sortedKeys(alertMetrics), ", "))
+		}
+		if rule.Operator != alertAbove && rule.Operator != alertBelow {
+			invalid(key+".operator", "must be %q or %q, got %q", alertAbove, alertBelow, rule.Operator)
+		}
+		if rule.Within != "" {
+			if d, err := time.ParseDuration(rule.Within); err != nil || d < 0 {
+				invalid(key+".within", "%q is not a duration such as \"48h\"", rule.Within)
+			} else if d > maxForecastDays*24*time.Hour {
+				invalid(key+".within", "forecasts only reach %d days ahead", maxForecastDays)
+			}
+		}
+		if rule.Metric == warningMetric {
+			if rule.Operator != alertAbove {
+				invalid(key+".operator", "metric %q only supports %q", warningMetric, alertAbove)
+			}
+			if top := len(warningSeverities); rule.Threshold < 0 || rule.Threshold > float64(top) {
+				invalid(key+".threshold", "metric %q takes a level from 0 to %d, got %g", warningMetric, top, rule.Threshold)
+			}
+		} else if ok && metric.current == nil && rule.within() <= 0 {
+			invalid(key+".within", "metric %q only exists in forecasts, so within is required", rule.Metric)
+		} else if ok && metric.hourly == nil && rule.within() > 0 {
+			invalid(key+".within", "metric %q is not forecast; remove within", rule.Metric)
+		}
+		if rule.Hysteresis < 0 {
+			invalid(key+".hysteresis", "must not be negative, got %g", rule.Hysteresis)
+		}
+		if rule.Severity != "" && !slices.Contains(alertSeverities, rule.Severity) {
+			invalid(key+".severity", "must be one of %s, got %q", strings.Join(alertSeverities, ", "), rule.Severity)
+		}
+	}
+}
+
+// AlertEvent is emitted when an alert starts firing or resolves. Values are
+// in canonical metric units; Message is formatted for people.
+type AlertEvent struct {
+	Rule      string    `json:"rule"`
+	Status    string    `json:"status"` // firing or resolved
+	Severity  string    `json:"severity"`
+	Location  Location  `json:"location"`
+	Metric    string    `json:"metric"`
+	Operator  string    `json:"operator"`
+	Threshold float64   `json:"threshold"`
+	Value     float64   `json:"value"`
+	At        time.Time `json:"at"`    // when the value was observed or is forecast
+	Since     time.Time `json:"since"` // when the alert started firing
+	Message   string    `json:"message"`
+
+	// Warning is the official warning that fired a warning_level rule.
+	Warning *WeatherAlert `json:"warning,omitempty"`
+}
+
+const alertSchema = `
+CREATE TABLE IF NOT EXISTS alert_state (
+	rule     TEXT    NOT NULL,
+	location TEXT    NOT NULL COLLATE NOCASE,
+	since    INTEGER NOT NULL,
+	value    REAL    NOT NULL,
+	PRIMARY KEY (rule, location)
+);
+`
+
+// alertStore remembers which alerts are firing, so a condition that lasts
+// over several checks, or several runs, is reported once.
+type alertStore struct {
+	db *sql.DB
+}
+
+func openAlertStore(path string) (*alertStore, error) {
+	db, err := openDatabase(path, alertSchema)
+	if err != nil {
+		return nil, err
+	}
+	return &alertStore{db: db}, nil
+}
+
+func (s *alertStore) Close() error {
+	return s.db.Close()
+}
+
+// firing returns when the alert for rule at location started, or false if
+// it is not firing.
+func (s *alertStore) firing(rule, location string) (time.Time, bool, error) {
+	var since int64
+	err := s.db.QueryRow(`SELECT since FROM alert_state WHERE rule = ? AND location = ?`, rule, location).Scan(&since)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("could not read alert state: %v", err)
+	}
+	return time.Unix(since, 0), true, nil
+}
+
+func (s *alertStore) fire(rule, location string, since time.Time, value float64) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO alert_state (rule, location, since, value) VALUES (?, ?, ?, ?)`,
+		rule, location, since.Unix(), value)
+	if err != nil {
+		return fmt.Errorf("could not save alert state: %v", err)
+	}
+	return nil
+}
+
+func (s *alertStore) resolve(rule, location string) error {
+	if _, err := s.db.Exec(`DELETE FROM alert_state WHERE rule = ? AND location = ?`, rule, location); err != nil {
+		return fmt.Errorf("could not save alert state: %v", err)
+	}
+	return nil
+}
+
+// prune forgets alerts whose rule is no longer configured.
+func (s *alertStore) prune(rules []AlertRule) error {
+	query := `DELETE FROM alert_state`
+	args := make([]any, len(rules))
+	if len(rules) > 0 {
+		for i, rule := range rules {
+			args[i] = rule.Name
+		}
+		query += ` WHERE rule NOT IN (?` + strings.Repeat(", ?", len(rules)-1) + `)`
+	}
+	if _, err := s.db.Exec(query, args...); err != nil {
+		return fmt.Errorf("could not save alert state: %v", err)
+	}
+	return nil
+}
+
+// activeAlert is a row of alert_state.
+type activeAlert struct {
+	Rule     string
+	Location string
+	Since    time.Time
+	Value    float64
+}
+
+func (s *alertStore) active() ([]activeAlert, error) {
+	rows, err := s.db.Query(`SELECT rule, location, since, value FROM alert_state ORDER BY since`)
+	if err != nil {
+		return nil, fmt.Errorf("could not read alert state: %v", err)
+	}
+	defer rows.Close()
+
+	var alerts []activeAlert
+	for rows.Next() {
+		var alert activeAlert
+		var since int64
+		if err := rows.Scan(&alert.Rule, &alert.Location, &since, &alert.Value); err != nil {
+			return nil, err
+		}
+		alert.Since = time.Unix(since, 0)
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+// alertChecker evaluates the configured rules against fresh data.
+type alertChecker struct {
+	rules           []AlertRule
+	defaultLocation string
+	providers       *providerSet
+	resolver        *LocationResolver
+	store           *alertStore
+	timeout         time.Duration
+
+	// notifier is nil when no notification sinks are configured.
+	notifier *notifier
+}
+
+func newAlertChecker(config Config, provider WeatherProvider, resolver *LocationResolver) (*alertChecker, error) {
+	store, err := openAlertStore(databasePath(config))
+	if err != nil {
+		return nil, err
+	}
+	_, timeout, err := fetchSettings(config)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	checker := &alertChecker{
+		rules:           config.Alerts,
+		defaultLocation: config.City,
+		providers:       newProviderSet(config, provider),
+		resolver:        resolver,
+		store:           store,
+		timeout:         timeout,
+	}
+	if len(config.Notifications) > 0 {
+		checker.notifier, err = newNotifier(config)
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+	return checker, nil
+}
+
+func (a *alertChecker) Close() error {
+	if a.notifier != nil {
+		a.notifier.Close()
+	}
+	return a.store.Close()
+}
+
+// Check fetches the forecast for every location with rules and returns an
+// event for each alert that started firing or resolved since the last
+// check, after delivering them to the notification sinks. A location that
+// cannot be fetched is skipped, keeping its alerts' state, and its error is
+// returned alongside the events, as are failed deliveries.
+//
+// A change of state is saved only once every sink that wants the event has
+// it, so an event whose delivery failed is raised again by the next check;
+// sinks that did get it then see it twice rather than others never.
+func (a *alertChecker) Check(ctx context.Context, now time.Time) ([]AlertEvent, error) {
+	if err := a.store.prune(a.rules); err != nil {
+		return nil, err
+	}
+
+	byLocation := make(map[string][]AlertRule)
+	for _, rule := range a.rules {
+		location := rule.Location
+		if location == "" {
+			location = a.defaultLocation
+		}
+		byLocation[location] = append(byLocation[location], rule)
+	}
+
+	var events []AlertEvent
+	var errs []error
+	for _, location := range sortedKeys(byLocation) {
+		rules := byLocation[location]
+		forecast, err := a.fetch(ctx, location, rules)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", location, err))
+			continue
+		}
+		units := a.providers.units(location)
+		for _, rule := range rules {
+			event, err := a.evaluate(rule, forecast, units, now)
+			if err != nil {
+				errs = append(errs, err)
+			} else if event != nil {
+				events = append(events, *event)
+			}
+		}
+	}
+	for _, event := range events {
+		if a.notifier != nil {
+			if err := a.notifier.Notify(ctx, event); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		if err := a.save(event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return events, errors.Join(errs...)
+}
+
+// save records the change of state event reports.
+func (a *alertChecker) save(event AlertEvent) error {
+	location := event.Location.DisplayName()
+	if event.Status == alertResolved {
+		return a.store.resolve(event.Rule, location)
+	}
+	return a.store.fire(event.Rule, location, event.Since, event.Value)
+}
+
+// fetch gets a forecast long enough for the longest horizon among rules.
+func (a *alertChecker) fetch(ctx context.Context, location string, rules []AlertRule) (*Forecast, error) {
+	var horizon time.Duration
+	for _, rule := range rules {
+		horizon = max(horizon, rule.within())
+	}
+	// Today counts as the first forecast day
+	days := min(int(math.Ceil(horizon.Hours()/24))+1, maxForecastDays)
+
+	provider, err := a.providers.provider(location)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := a.resolver.Resolve(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	forecast, err := provider.Forecast(ctx, loc.Query(), days)
+	if err != nil {
+		return nil, err
+	}
+	forecast.Location = loc.canonical(forecast.Location)
+	forecast.Current.Location = forecast.Location
+	return forecast, nil
+}
+
+// evaluate applies rule to forecast and returns the change of state, which
+// Check saves once it is delivered, or nil when nothing changed. Messages
+// show values in units.
+func (a *alertChecker) evaluate(rule AlertRule, forecast *Forecast, units UnitSystem, now time.Time) (*AlertEvent, error) {
+	value, at, ok := rule.worst(forecast, now)
+	if !ok {
+		return nil, nil
+	}
+
+	location := forecast.Location.DisplayName()
+	since, firing, err := a.store.firing(rule.Name, location)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &AlertEvent{
+		Rule:      rule.Name,
+		Severity:  rule.severity(),
+		Location:  forecast.Location,
+		Metric:    rule.Metric,
+		Operator:  rule.Operator,
+		Threshold: rule.Threshold,
+		Value:     value,
+		At:        at,
+	}
+	format := alertMetrics[rule.Metric].format
+	switch {
+	case !firing && rule.breached(value):
+		event.Status = alertFiring
+		event.Since = now
+		when := "now"
+		if at.After(now) {
+			when = "forecast for " + forecast.Location.localTime(at).Format("Mon 15:04")
+		}
+		event.Message = fmt.Sprintf("%s: %s %s %s at %s (%s %s)", rule.Name, rule.Metric, rule.Operator,
+			format(units, rule.Threshold), location, format(units, value), when)
+		if rule.Metric == warningMetric {
+			// worst reports level 0 when no warning is in force.
+			event.Warning = rule.worstWarning(forecast, now)
+			if event.Warning != nil {
+				event.Message = fmt.Sprintf("%s: %s at %s (%s)", rule.Name, event.Warning.Headline, location, when)
+			}
+		}
+	case firing && rule.cleared(value):
+		event.Status = alertResolved
+		event.Since = since
+		event.Message = fmt.Sprintf("%s resolved at %s: %s back to %s", rule.Name, location, rule.Metric,
+			format(units, value))
+	default:
+		return nil, nil
+	}
+	return event, nil
+}
+
+// runAlerts implements the "alerts" command.
+func runAlerts(ctx context.Context, args []string) error {
+	flags := newCommandFlags("alerts")
+	output := addOutputFlag(flags)
+	limit := flags.Int("limit", 50, "number of deliveries \"log\" shows")
+	flags.Parse(args)
+	if err := checkOutputFormat(*output); err != nil {
+		return err
+	}
+
+	config, _ := loadConfig()
+	switch flags.Arg(0) {
+	case "check":
+		return checkAlerts(ctx, config, *output)
+	case "status":
+		return alertStatus(config)
+	case "test":
+		return testNotifications(ctx, config, flags.Args()[1:])
+	case "log":
+		if *limit < 1 {
+			return usageError("-limit must be at least 1, got %d", *limit)
+		}
+		return deliveryLog(config, *output, *limit)
+	default:
+		return usageError("usage: alerts [-output format] [-limit n] check|status|test [sink...]|log")
+	}
+}
+
+func checkAlerts(ctx context.Context, config Config, output string) error {
+	if len(config.Alerts) == 0 {
+		return configError(fmt.Errorf("no alert rules configured; add them under \"alerts\" in %s", configFileName))
+	}
+
+	provider, err := newProvider(config)
+	if err != nil {
+		return err
+	}
+	resolver, err := newLocationResolver(config, false)
+	if err != nil {
+		return err
+	}
+	checker, err := newAlertChecker(config, provider, resolver)
+	if err != nil {
+		return err
+	}
+	defer checker.Close()
+
+	events, checkErr := checker.Check(ctx, time.Now())
+	if output != outputText {
+		if err := writeDocument(os.Stdout, output, newAlertsDocument(events)); err != nil {
+			return err
+		}
+		return checkErr
+	}
+
+	if len(events) == 0 {
+		fmt.Println("No alert changes")
+	}
+	for _, event := range events {
+		fmt.Println(formatAlertEvent(event))
+	}
+	return checkErr
+}
+
+// testNotifications sends a made-up event to the named sinks, or to all of
+// them, ignoring their filters, so a new sink can be tried without waiting
+// for the weather.
+func testNotifications(ctx context.Context, config Config, names []string) error {
+	if len(config.Notifications) == 0 {
+		return configError(fmt.Errorf("no notification sinks configured; add them under \"notifications\" in %s", configFileName))
+	}
+	var sinks []NotificationSink
+	for _, sink := range config.Notifications {
+		if len(names) == 0 || slices.Contains(names, sink.Name) {
+			sinks = append(sinks, sink)
+		}
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(sinks, func(sink NotificationSink) bool { return sink.Name == name }) {
+			return usageError("no notification sink is named %q", name)
+		}
+	}
+
+	n, err := newNotifier(config)
+	if err != nil {
+		return err
+	}
+	defer n.Close()
+
+	now := time.Now()
+	event := AlertEvent{
+		Rule:     "test",
+		Status:   alertFiring,
+		Severity: "info",
+		Location: Location{Name: config.City},
+		Metric:   "temp",
+		Operator: alertBelow,
+		At:       now,
+		Since:    now,
+		Message:  "test notification from weather-analyzer",
+	}
+	var errs []error
+	for _, sink := range sinks {
+		if err := n.deliver(ctx, sink, event); err != nil {
+			fmt.Printf("❌ %s: %v\n", sink.Name, err)
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Printf("✅ %s: delivered\n", sink.Name)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d test notifications failed", len(errs), len(sinks))
+	}
+	return nil
+}
+
+func deliveryLog(config Config, output string, limit int) error {
+	n, err := newNotifier(config)
+	if err != nil {
+		return err
+	}
+	defer n.Close()
+
+	deliveries, err := n.deliveries(limit)
+	if err != nil {
+		return err
+	}
+	if output != outputText {
+		return writeDocument(os.Stdout, output, newDeliveriesDocument(deliveries))
+	}
+
+	fmt.Println("\n📬 Alert Deliveries")
+	fmt.Println("===================")
+	if len(deliveries) == 0 {
+		fmt.Println("No deliveries yet")
+	}
+	for _, d := range deliveries {
+		result := "delivered"
+		if !d.Delivered {
+			result = "failed: " + d.Error
+		}
+		fmt.Printf("%s %-12s %s %s at %s, %d attempt(s), %s\n", d.Time.Local().Format("2006-01-02 15:04"),
+			d.Sink, d.Rule, d.Status, d.Location, d.Attempts, result)
+	}
+	return nil
+}
+
+// formatAlertEvent renders an event as one line for logs and the terminal.
+func formatAlertEvent(event AlertEvent) string {
+	icon := "🚨"
+	if event.Status == alertResolved {
+		icon = "✅"
+	}
+	return fmt.Sprintf("%s [%s] %s", icon, event.Severity, event.Message)
+}
+
+func alertStatus(config Config) error {
+	store, err := openAlertStore(databasePath(config))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	active, err := store.active()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\n🔔 Alert Rules")
+	fmt.Println("==============")
+	if len(config.Alerts) == 0 {
+		fmt.Println("No alert rules configured")
+	}
+	rules := append([]AlertRule(nil), config.Alerts...)
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	for _, rule := range rules {
+		location := rule.Location
+		if location == "" {
+			location = config.City
+		}
+		horizon := "now"
+		if rule.Within != "" {
+			horizon = "within " + rule.Within
+		}
+		fmt.Printf("%-20s %s %s %g %s at %s [%s]\n", rule.Name, rule.Metric, rule.Operator, rule.Threshold,
+			horizon, location, rule.severity())
+	}
+
+	fmt.Println("\nFiring:")
+	if len(active) == 0 {
+		fmt.Println("  none")
+	}
+	for _, alert := range active {
+		fmt.Printf("  %s at %s since %s (value %g)\n", alert.Rule, alert.Location,
+			alert.Since.Local().Format("2006-01-02 15:04"), alert.Value)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testChecker returns a checker for rules at the saved location yard, with
+// its state in a temporary database.
+func testChecker(t *testing.T, provider WeatherProvider, rules []AlertRule, sinks ...NotificationSink) *alertChecker {
+	t.Helper()
+	config := Config{
+		DatabasePath:  filepath.Join(t.TempDir(), "weather.db"),
+		City:          yard.Alias,
+		Locations:     []SavedLocation{yard},
+		Alerts:        rules,
+		Notifications: sinks,
+	}
+	resolver := &LocationResolver{saved: config.Locations}
+	checker, err := newAlertChecker(config, provider, resolver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { checker.Close() })
+	return checker
+}
+
+func TestCheckFiresAndResolves(t *testing.T) {
+	provider := &fakeProvider{forecast: Forecast{Current: WeatherData{TempC: -3}}}
+	rules := []AlertRule{{Name: "frost", Metric: "temp", Operator: alertBelow, Threshold: 0, Hysteresis: 1}}
+	checker := testChecker(t, provider, rules)
+	now := time.Now()
+
+	events, err := checker.Check(context.Background(), now)
+	if err != nil || len(events) != 1 || events[0].Status != alertFiring || events[0].Value != -3 {
+		t.Fatalf("first check: %+v, %v", events, err)
+	}
+	if events, err := checker.Check(context.Background(), now); err != nil || len(events) != 0 {
+		t.Errorf("repeated check: %+v, %v", events, err)
+	}
+
+	// Within the hysteresis the alert keeps firing.
+	provider.forecast.Current.TempC = 0.5
+	if events, err := checker.Check(context.Background(), now); err != nil || len(events) != 0 {
+		t.Errorf("check within hysteresis: %+v, %v", events, err)
+	}
+
+	provider.forecast.Current.TempC = 2
+	events, err = checker.Check(context.Background(), now)
+	if err != nil || len(events) != 1 || events[0].Status != alertResolved {
+		t.Errorf("check after thaw: %+v, %v", events, err)
+	}
+}
+
+func TestCheckRedeliversFailedEvents(t *testing.T) {
+	server := newWebhookServer(t, http.StatusServiceUnavailable)
+	provider := &fakeProvider{forecast: Forecast{Current: WeatherData{TempC: -3}}}
+	rules := []AlertRule{{Name: "frost", Metric: "temp", Operator: alertBelow, Threshold: 0}}
+	checker := testChecker(t, provider, rules, NotificationSink{Name: "hook", Type: sinkWebhook, URL: server.URL})
+	now := time.Now()
+
+	events, err := checker.Check(context.Background(), now)
+	if err == nil || len(events) != 1 {
+		t.Fatalf("failed delivery: %+v, %v", events, err)
+	}
+	if _, firing, _ := checker.store.firing("frost", "Yard"); firing {
+		t.Fatal("alert marked as fired although it was not delivered")
+	}
+
+	events, err = checker.Check(context.Background(), now)
+	if err != nil || len(events) != 1 || events[0].Status != alertFiring {
+		t.Fatalf("redelivery: %+v, %v", events, err)
+	}
+	if _, firing, _ := checker.store.firing("frost", "Yard"); !firing {
+		t.Error("alert not marked as fired after delivery")
+	}
+	if got := len(server.requests()); got != 2 {
+		t.Errorf("got %d webhook requests, want 2", got)
+	}
+
+	if events, err := checker.Check(context.Background(), now); err != nil || len(events) != 0 {
+		t.Errorf("check after delivery: %+v, %v", events, err)
+	}
+}
+
+func TestValidateWarningLevelThreshold(t *testing.T) {
+	for _, threshold := range []float64{-1, 6} {
+		rules := []AlertRule{{Name: "official", Metric: warningMetric, Operator: alertAbove, Threshold: threshold}}
+		var problems []string
+		validateAlertRules(rules, func(key, format string, args ...any) {
+			problems = append(problems, key+" "+fmt.Sprintf(format, args...))
+		})
+		if len(problems) != 1 || !strings.HasPrefix(problems[0], "alerts[0].threshold") {
+			t.Errorf("threshold %g: problems = %q", threshold, problems)
+		}
+	}
+}
+
+func TestWarningLevelEvents(t *testing.T) {
+	now := time.Now()
+	warning := WeatherAlert{Event: "Yellow wind warning", Headline: "Yellow warning of wind", Severity: "Moderate",
+		Effective: now.Add(-time.Hour), Expires: now.Add(time.Hour)}
+	provider := &fakeProvider{forecast: Forecast{Alerts: []WeatherAlert{warning}}}
+	rules := []AlertRule{{Name: "official", Metric: warningMetric, Operator: alertAbove, Threshold: 1}}
+	checker := testChecker(t, provider, rules)
+
+	events, err := checker.Check(context.Background(), now)
+	if err != nil || len(events) != 1 {
+		t.Fatalf("check: %+v, %v", events, err)
+	}
+	if events[0].Warning == nil || events[0].Message != "official: Yellow warning of wind at Yard (now)" {
+		t.Errorf("event = %+v", events[0])
+	}
+
+	provider.forecast.Alerts = nil
+	events, err = checker.Check(context.Background(), now)
+	if err != nil || len(events) != 1 || events[0].Status != alertResolved || events[0].Warning != nil {
+		t.Errorf("check after expiry: %+v, %v", events, err)
+	}
+}
+
+// A rule that breaches with no warning in force, as a threshold below 0
+// did before validation caught it, must not crash the checker.
+func TestWarningLevelWithoutWarning(t *testing.T) {
+	provider := &fakeProvider{}
+	rules := []AlertRule{{Name: "official", Metric: warningMetric, Operator: alertAbove, Threshold: -1}}
+	checker := testChecker(t, provider, rules)
+
+	events, err := checker.Check(context.Background(), time.Now())
+	if err != nil || len(events) != 1 || events[0].Warning != nil || events[0].Message == "" {
+		t.Errorf("check: %+v, %v", events, err)
+	}
+}
+
+func TestWarningLevelOfUnknownSeverity(t *testing.T) {
+	now := time.Now()
+	warning := WeatherAlert{Event: "Fog", Headline: "Fog advisory", Severity: "Unknown", Effective: now.Add(-time.Hour)}
+	provider := &fakeProvider{}
+	rules := []AlertRule{{Name: "any", Metric: warningMetric, Operator: alertAbove, Threshold: 0}}
+	checker := testChecker(t, provider, rules)
+
+	// No warning is level 0, which is not above the threshold.
+	if events, err := checker.Check(context.Background(), now); err != nil || len(events) != 0 {
+		t.Fatalf("check without warnings: %+v, %v", events, err)
+	}
+
+	provider.forecast.Alerts = []WeatherAlert{warning}
+	events, err := checker.Check(context.Background(), now)
+	if err != nil || len(events) != 1 || events[0].Status != alertFiring || events[0].Value != 1 {
+		t.Errorf("check with a warning of unknown severity: %+v, %v", events, err)
+	}
+	if got := formatWarningLevel(UnitSystem{}, 1); got != "unknown" {
+		t.Errorf("level 1 = %q, want unknown", got)
+	}
+}
+
+func TestCheckUsesSavedLocationUnits(t *testing.T) {
+	garden := yard
+	garden.Units = "imperial"
+	config := Config{
+		DatabasePath: filepath.Join(t.TempDir(), "weather.db"),
+		Units:        "metric",
+		Locations:    []SavedLocation{garden},
+		Alerts:       []AlertRule{{Name: "frost", Location: "yard", Metric: "temp", Operator: alertBelow, Threshold: 0}},
+	}
+	provider := &fakeProvider{forecast: Forecast{Current: WeatherData{TempC: -5}}}
+	checker, err := newAlertChecker(config, provider, &LocationResolver{saved: config.Locations})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer checker.Close()
+
+	events, err := checker.Check(context.Background(), time.Now())
+	if err != nil || len(events) != 1 || !strings.Contains(events[0].Message, "below 32.0°F") {
+		t.Errorf("events = %+v, %v; want the threshold in °F", events, err)
+	}
+}
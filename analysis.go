@@ -3,22 +3,28 @@ package main
 import (
 	"fmt"
 	"math"
+	"strings"
 	"time"
 )
 
 type AnalysisResult struct {
-	AverageTemp    float64   `json:"average_temp"`
-	MaxTemp        float64   `json:"max_temp"`
-	MinTemp        float64   `json:"min_temp"`
-	TempRange      float64   `json:"temp_range"`
-	DataPoints     int       `json:"data_points"`
-	TimePeriod     string    `json:"time_period"`
-	Trend          string    `json:"trend"`
-	Recommendation string    `json:"recommendation"`
+	AverageTemp    float64 `json:"average_temp_c"`
+	MaxTemp        float64 `json:"max_temp_c"`
+	MinTemp        float64 `json:"min_temp_c"`
+	TempRange      float64 `json:"temp_range_c"`
+	DataPoints     int     `json:"data_points"`
+	TimePeriod     string  `json:"time_period"`
+	Trend          string  `json:"trend"`
+	Recommendation string  `json:"recommendation"`
+
+	Derived DerivedSummary `json:"derived"`
+
+	// AirQuality is nil when no reading has air quality data.
+	AirQuality *AirQualitySummary `json:"air_quality,omitempty"`
 }
 
-func analyzeAndVisualize() error {
-	data, err := loadWeatherData()
+func analyzeAndVisualize(loc Location, period time.Duration, chart string, units UnitSystem) error {
+	data, err := loadWeatherData(loc, period)
 	if err != nil {
 		return fmt.Errorf("could not load weather data: %v", err)
 	}
@@ -29,8 +35,8 @@ func analyzeAndVisualize() error {
 	}
 
 	result := analyzeData(data)
-	displayAnalysis(result)
-	displaySimpleChart(data)
+	displayAnalysis(result, units)
+	displaySimpleChart(data, chart, units)
 
 	return nil
 }
@@ -41,26 +47,26 @@ func analyzeData(data []WeatherData) AnalysisResult {
 	}
 
 	var sumTemp float64
-	maxTemp := data[0].Temp
-	minTemp := data[0].Temp
+	maxTemp := data[0].TempC
+	minTemp := data[0].TempC
 
 	for _, item := range data {
-		sumTemp += item.Temp
-		if item.Temp > maxTemp {
-			maxTemp = item.Temp
+		sumTemp += item.TempC
+		if item.TempC > maxTemp {
+			maxTemp = item.TempC
 		}
-		if item.Temp < minTemp {
-			minTemp = item.Temp
+		if item.TempC < minTemp {
+			minTemp = item.TempC
 		}
 	}
 
 	avgTemp := sumTemp / float64(len(data))
-	
+
 	// Determine trend
 	var trend string
 	if len(data) >= 2 {
-		latest := data[len(data)-1].Temp
-		earliest := data[0].Temp
+		latest := data[len(data)-1].TempC
+		earliest := data[0].TempC
 		if latest > earliest+0.5 {
 			trend = "warming"
 		} else if latest < earliest-0.5 {
@@ -84,6 +90,8 @@ func analyzeData(data []WeatherData) AnalysisResult {
 		TimePeriod:     calculateTimePeriod(data),
 		Trend:          trend,
 		Recommendation: recommendation,
+		Derived:        summarizeDerived(data),
+		AirQuality:     summarizeAirQuality(data),
 	}
 }
 
@@ -91,11 +99,11 @@ func calculateTimePeriod(data []WeatherData) string {
 	if len(data) < 2 {
 		return "Single reading"
 	}
-	
+
 	start := data[0].Timestamp
 	end := data[len(data)-1].Timestamp
 	duration := end.Sub(start)
-	
+
 	return fmt.Sprintf("%.1f hours", duration.Hours())
 }
 
@@ -114,33 +122,68 @@ func generateRecommendation(avgTemp float64) string {
 	}
 }
 
-func displayAnalysis(result AnalysisResult) {
+// displayAnalysis prints an analysis; its temperatures are in °C and are
+// converted to units here.
+func displayAnalysis(result AnalysisResult, units UnitSystem) {
 	fmt.Println("\n=== WEATHER ANALYSIS ===")
 	fmt.Printf("Data Points: %d\n", result.DataPoints)
 	fmt.Printf("Time Period: %s\n", result.TimePeriod)
-	fmt.Printf("Average Temperature: %.1f°C\n", result.AverageTemp)
-	fmt.Printf("Temperature Range: %.1f°C (Min: %.1f°C, Max: %.1f°C)\n", 
-		result.TempRange, result.MinTemp, result.MaxTemp)
+	fmt.Printf("Average Temperature: %s\n", units.FormatTemp(result.AverageTemp))
+	fmt.Printf("Temperature Range: %s (Min: %s, Max: %s)\n",
+		units.FormatTempDelta(result.TempRange), units.FormatTemp(result.MinTemp), units.FormatTemp(result.MaxTemp))
 	fmt.Printf("Trend: %s\n", result.Trend)
 	fmt.Printf("Recommendation: %s\n", result.Recommendation)
-	fmt.Println("========================\n")
+	if derived := result.Derived; derived.AverageDewPointC != nil {
+		fmt.Printf("Dew Point: %s average; Heat Index: %s max; Wind Chill: %s min\n",
+			units.FormatTemp(*derived.AverageDewPointC), units.FormatTemp(*derived.MaxHeatIndexC), units.FormatTemp(derived.MinWindChillC))
+		fmt.Printf("Humidex: %.0f max; Wet Bulb: %s max; Absolute Humidity: %.1f g/m³ average\n",
+			*derived.MaxHumidex, units.FormatTemp(*derived.MaxWetBulbC), *derived.AverageAbsoluteHumidityGm3)
+	} else {
+		fmt.Printf("Wind Chill: %s min; no humidity for the other derived metrics\n", units.FormatTemp(derived.MinWindChillC))
+	}
+	if aq := result.AirQuality; aq != nil {
+		fmt.Printf("Air Quality: US AQI average %d, worst %d (%s); UK DAQI worst %d (%s) over %d readings\n",
+			aq.AverageUSEPA, aq.MaxUSEPA, aq.MaxUSEPACategory, aq.MaxUKDEFRA, aq.MaxUKDEFRABand, aq.Readings)
+		fmt.Printf("Health Advice: %s\n", aq.HealthAdvice)
+	}
+	fmt.Println("========================")
+	fmt.Println()
+}
+
+// chartMetrics lists the metrics displaySimpleChart can chart: those of
+// alertMetrics that stored readings have.
+func chartMetrics() []string {
+	var names []string
+	for _, name := range sortedKeys(alertMetrics) {
+		if alertMetrics[name].current != nil {
+			names = append(names, name)
+		}
+	}
+	return names
 }
 
-func displaySimpleChart(data []WeatherData) {
+// displaySimpleChart charts one of chartMetrics for the latest readings.
+func displaySimpleChart(data []WeatherData, chart string, units UnitSystem) {
 	if len(data) == 0 {
 		return
 	}
+	metric := alertMetrics[chart]
+	title := strings.ToUpper(strings.ReplaceAll(chart, "_", " "))
+	if chart == "temp" {
+		title = "TEMPERATURE"
+	}
 
-	fmt.Println("TEMPERATURE TREND CHART:")
-	fmt.Println("Time                | Temp (°C)")
+	fmt.Printf("%s TREND CHART:\n", title)
+	fmt.Printf("Time                | %s\n", metric.header(chart, units))
 	fmt.Println("--------------------|-----------")
-	
-	for i, item := range data {
-		if i >= 10 { // Limit display to last 10 readings
-			break
-		}
+
+	for _, item := range data[max(0, len(data)-10):] { // Limit display to last 10 readings
 		timeStr := item.Timestamp.Format("15:04:05")
-		fmt.Printf("%-19s | %6.1f°C\n", timeStr, item.Temp)
+		value := "n/a"
+		if v := metric.current(item); !math.IsNaN(v) {
+			value = metric.format(units, v)
+		}
+		fmt.Printf("%-19s | %8s\n", timeStr, value)
 	}
 	fmt.Println()
-}
\ No newline at end of file
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChartShowsLastTenReadings(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	var data []WeatherData
+	for i := 0; i < 15; i++ {
+		data = append(data, WeatherData{Timestamp: start.Add(time.Duration(i) * time.Hour), TempC: float64(i)})
+	}
+
+	out := captureStdout(t, func() { displaySimpleChart(data, "temp", UnitSystem{}) })
+	rows := strings.Split(strings.TrimSpace(out), "\n")[3:]
+	if len(rows) != 10 || !strings.HasPrefix(rows[0], "05:00:00") || !strings.HasPrefix(rows[9], "14:00:00") {
+		t.Errorf("rows = %q, want 05:00 to 14:00", rows)
+	}
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"fmt"
 	"math"
+	"sort"
 	"strings"
+	"time"
 )
 
 func (wa *WeatherAnalyzer) DisplayCurrentWeather() {
@@ -13,13 +15,57 @@ func (wa *WeatherAnalyzer) DisplayCurrentWeather() {
 	}
 
 	data := wa.Data[0]
-	
+	units := wa.Units
+
+	displayWeatherAlerts(data.Location, data.Alerts, time.Now())
+
 	fmt.Printf("\n📍 Current Weather in %s, %s\n", data.Location.Name, data.Location.Country)
 	fmt.Println("====================================")
-	fmt.Printf("🌡️  Temperature: %.1f°C (Feels like: %.1f°C)\n", data.Current.TempC, data.Current.FeelsLikeC)
-	fmt.Printf("☁️  Condition: %s\n", data.Current.Condition.Text)
+	fmt.Printf("🌡️  Temperature: %s (Feels like: %s)\n", units.FormatTemp(data.Current.TempC), units.FormatTemp(data.Current.FeelsLikeC))
+	fmt.Printf("☁️  Condition: %s\n", data.Current.Condition)
 	fmt.Printf("💧 Humidity: %d%%\n", data.Current.Humidity)
-	fmt.Printf("💨 Wind: %.1f km/h\n", data.Current.WindKph)
+	fmt.Printf("💨 Wind: %s\n", units.FormatSpeed(data.Current.WindKph))
+	fmt.Printf("🧮 %s\n", data.Current.Derived().format(units))
+	displayAirQuality(data.Current.AirQuality)
+}
+
+// displayWeatherAlerts prints the official warnings still in force, most
+// severe first, ahead of everything else.
+func displayWeatherAlerts(location Location, alerts []WeatherAlert, now time.Time) {
+	var active []WeatherAlert
+	for _, alert := range alerts {
+		if alert.inForce(now) {
+			active = append(active, alert)
+		}
+	}
+	if len(active) == 0 {
+		return
+	}
+	sort.SliceStable(active, func(i, j int) bool { return active[i].level() > active[j].level() })
+
+	fmt.Printf("\n🚨 OFFICIAL WEATHER WARNINGS (%d)\n", len(active))
+	fmt.Println("====================================")
+	for _, alert := range active {
+		fmt.Printf("⚠️  [%s] %s\n", strings.ToUpper(alert.Severity), alert.Headline)
+		if alert.Urgency != "" {
+			fmt.Printf("   Urgency: %s\n", alert.Urgency)
+		}
+		if len(alert.Areas) > 0 {
+			fmt.Printf("   Areas: %s\n", strings.Join(alert.Areas, "; "))
+		}
+		switch {
+		case alert.Effective.After(now) && !alert.Expires.IsZero():
+			fmt.Printf("   From %s until %s\n", location.localTime(alert.Effective).Format("Mon 2 Jan 15:04"),
+				location.localTime(alert.Expires).Format("Mon 2 Jan 15:04"))
+		case alert.Effective.After(now):
+			fmt.Printf("   From %s\n", location.localTime(alert.Effective).Format("Mon 2 Jan 15:04"))
+		case !alert.Expires.IsZero():
+			fmt.Printf("   Until %s\n", location.localTime(alert.Expires).Format("Mon 2 Jan 15:04"))
+		}
+		if alert.Instruction != "" {
+			fmt.Printf("   %s\n", alert.Instruction)
+		}
+	}
 }
 
 func (wa *WeatherAnalyzer) AnalyzeTemperatureTrends() {
@@ -28,34 +74,33 @@ func (wa *WeatherAnalyzer) AnalyzeTemperatureTrends() {
 	}
 
 	data := wa.Data[0]
-	forecastDays := data.Forecast.Forecastday
+	forecastDays := data.Days
+	units := wa.Units
 
 	if len(forecastDays) == 0 {
 		return
 	}
 
-	fmt.Printf("\n📈 7-Day Temperature Trends for %s\n", data.Location.Name)
+	fmt.Printf("\n📈 %d-Day Temperature Trends for %s\n", len(forecastDays), data.Location.Name)
 	fmt.Println("====================================")
 
 	// Calculate statistics
 	var maxTemps, minTemps, avgTemps []float64
 	var totalMax, totalMin, totalAvg float64
 
-	for i, day := range forecastDays {
-		maxTemps = append(maxTemps, day.Day.MaxTempC)
-		minTemps = append(minTemps, day.Day.MinTempC)
-		avgTemps = append(avgTemps, day.Day.AvgTempC)
-		
-		totalMax += day.Day.MaxTempC
-		totalMin += day.Day.MinTempC
-		totalAvg += day.Day.AvgTempC
+	for _, day := range forecastDays {
+		maxTemps = append(maxTemps, day.MaxTempC)
+		minTemps = append(minTemps, day.MinTempC)
+		avgTemps = append(avgTemps, day.AvgTempC)
 
-		// Parse date for display
-		date, _ := time.Parse("2006-01-02", day.Date)
-		dayName := date.Format("Monday")
+		totalMax += day.MaxTempC
+		totalMin += day.MinTempC
+		totalAvg += day.AvgTempC
 
-		fmt.Printf("%s: Max: %.1f°C, Min: %.1f°C, Avg: %.1f°C\n", 
-			dayName, day.Day.MaxTempC, day.Day.MinTempC, day.Day.AvgTempC)
+		dayName := day.Date.Format("Monday")
+
+		fmt.Printf("%s: Max: %s, Min: %s, Avg: %s\n",
+			dayName, units.FormatTemp(day.MaxTempC), units.FormatTemp(day.MinTempC), units.FormatTemp(day.AvgTempC))
 	}
 
 	// Calculate averages
@@ -69,16 +114,59 @@ func (wa *WeatherAnalyzer) AnalyzeTemperatureTrends() {
 	tempRange := maxTemp - minTemp
 
 	fmt.Printf("\n📊 Temperature Statistics:\n")
-	fmt.Printf("Average High: %.1f°C\n", avgMax)
-	fmt.Printf("Average Low: %.1f°C\n", avgMin)
-	fmt.Printf("Overall Average: %.1f°C\n", overallAvg)
-	fmt.Printf("Temperature Range: %.1f°C\n", tempRange)
-	fmt.Printf("Highest Temp: %.1f°C\n", maxTemp)
-	fmt.Printf("Lowest Temp: %.1f°C\n", minTemp)
+	fmt.Printf("Average High: %s\n", units.FormatTemp(avgMax))
+	fmt.Printf("Average Low: %s\n", units.FormatTemp(avgMin))
+	fmt.Printf("Overall Average: %s\n", units.FormatTemp(overallAvg))
+	fmt.Printf("Temperature Range: %s\n", units.FormatTempDelta(tempRange))
+	fmt.Printf("Highest Temp: %s\n", units.FormatTemp(maxTemp))
+	fmt.Printf("Lowest Temp: %s\n", units.FormatTemp(minTemp))
 
 	// Trend analysis
 	trend := analyzeTrend(avgTemps)
 	fmt.Printf("Trend: %s\n", trend)
+
+	displayHourlyHighlights(data.Location, forecastDays[0], units)
+}
+
+// displayHourlyHighlights answers "when is it warmest/wettest" for one day.
+func displayHourlyHighlights(location Location, day ForecastDay, units UnitSystem) {
+	if len(day.Hours) == 0 {
+		return
+	}
+
+	warmest, wettest, windiest := hourlyHighlights(day.Hours)
+	minHumidity, maxHumidity := day.Hours[0].Humidity, day.Hours[0].Humidity
+	for _, hour := range day.Hours {
+		minHumidity = min(minHumidity, hour.Humidity)
+		maxHumidity = max(maxHumidity, hour.Humidity)
+	}
+
+	fmt.Printf("\n⏰ Hourly Outlook for %s:\n", day.Date.Format("Monday"))
+	fmt.Printf("Warmest: %s (%s)\n", location.localTime(warmest.Time).Format("15:04"), units.FormatTemp(warmest.TempC))
+	fmt.Printf("Wettest: %s (%d%% chance, %s)\n",
+		location.localTime(wettest.Time).Format("15:04"), wettest.ChanceOfRain, units.FormatPrecip(wettest.PrecipMm))
+	fmt.Printf("Windiest: %s (%s)\n", location.localTime(windiest.Time).Format("15:04"), units.FormatSpeed(windiest.WindKph))
+	fmt.Printf("Humidity: %d%% - %d%%\n", minHumidity, maxHumidity)
+}
+
+// hourlyHighlights returns the warmest, wettest and windiest hours. Ties on
+// chance of rain are broken by the expected amount.
+func hourlyHighlights(hours []ForecastHour) (warmest, wettest, windiest ForecastHour) {
+	warmest, wettest, windiest = hours[0], hours[0], hours[0]
+
+	for _, hour := range hours {
+		if hour.TempC > warmest.TempC {
+			warmest = hour
+		}
+		if hour.ChanceOfRain > wettest.ChanceOfRain ||
+			(hour.ChanceOfRain == wettest.ChanceOfRain && hour.PrecipMm > wettest.PrecipMm) {
+			wettest = hour
+		}
+		if hour.WindKph > windiest.WindKph {
+			windiest = hour
+		}
+	}
+	return warmest, wettest, windiest
 }
 
 func findExtremes(maxTemps, minTemps []float64) (float64, float64) {
@@ -133,8 +221,13 @@ func (wa *WeatherAnalyzer) VisualizeTemperatureTrends() {
 		return
 	}
 
+	if wa.ChartMode == chartModeHourly {
+		wa.visualizeHourlyTemperatures()
+		return
+	}
+
 	data := wa.Data[0]
-	forecastDays := data.Forecast.Forecastday
+	forecastDays := data.Days
 
 	if len(forecastDays) == 0 {
 		return
@@ -154,17 +247,19 @@ func (wa *WeatherAnalyzer) VisualizeTemperatureTrends() {
 	displayMin := minTemp - rangeAdjust
 	displayMax := maxTemp + rangeAdjust
 	tempRange := displayMax - displayMin
+	if tempRange == 0 {
+		tempRange = 1 // Avoid division by zero
+	}
 
 	const chartWidth = 50
 
 	for i, day := range forecastDays {
-		date, _ := time.Parse("2006-01-02", day.Date)
-		dayName := date.Format("Mon")
+		dayName := day.Date.Format("Mon")
 
 		// Calculate bar positions
-		maxPos := int(((day.Day.MaxTempC - displayMin) / tempRange) * chartWidth)
-		minPos := int(((day.Day.MinTempC - displayMin) / tempRange) * chartWidth)
-		avgPos := int(((day.Day.AvgTempC - displayMin) / tempRange) * chartWidth)
+		maxPos := int(((day.MaxTempC - displayMin) / tempRange) * chartWidth)
+		minPos := int(((day.MinTempC - displayMin) / tempRange) * chartWidth)
+		avgPos := int(((day.AvgTempC - displayMin) / tempRange) * chartWidth)
 
 		// Create visualization bar
 		bar := make([]rune, chartWidth+10)
@@ -184,9 +279,9 @@ func (wa *WeatherAnalyzer) VisualizeTemperatureTrends() {
 			}
 		}
 
-		fmt.Printf("%s: %s Max:%.1f°C\n", dayName, string(bar), day.Day.MaxTempC)
-		fmt.Printf("      %s Min:%.1f°C\n", strings.Repeat(" ", minPos), day.Day.MinTempC)
-		
+		fmt.Printf("%s: %s Max:%s\n", dayName, string(bar), wa.Units.FormatTemp(day.MaxTempC))
+		fmt.Printf("      %s Min:%s\n", strings.Repeat(" ", minPos), wa.Units.FormatTemp(day.MinTempC))
+
 		if i < len(forecastDays)-1 {
 			fmt.Println()
 		}
@@ -195,32 +290,55 @@ func (wa *WeatherAnalyzer) VisualizeTemperatureTrends() {
 	fmt.Printf("\nLegend: ❄ Low | ● Avg | 🔥 High\n")
 }
 
-func getMaxTemps(days []struct {
-	Date string `json:"date"`
-	Day  struct {
-		MaxTempC float64 `json:"maxtemp_c"`
-		MinTempC float64 `json:"mintemp_c"`
-		AvgTempC float64 `json:"avgtemp_c"`
-	} `json:"day"`
-}) []float64 {
+// visualizeHourlyTemperatures charts each hour of the first forecast day.
+func (wa *WeatherAnalyzer) visualizeHourlyTemperatures() {
+	data := wa.Data[0]
+	if len(data.Days) == 0 || len(data.Days[0].Hours) == 0 {
+		fmt.Println("\nNo hourly forecast available")
+		return
+	}
+
+	day := data.Days[0]
+	fmt.Printf("\n📊 Hourly Temperature Visualization (%s)\n", day.Date.Format("Monday"))
+	fmt.Println("============================")
+
+	var temps []float64
+	for _, hour := range day.Hours {
+		temps = append(temps, hour.TempC)
+	}
+	minTemp, maxTemp := findMinMax(temps)
+	tempRange := maxTemp - minTemp
+	if tempRange == 0 {
+		tempRange = 1 // Avoid division by zero
+	}
+
+	const chartWidth = 40
+
+	for _, hour := range day.Hours {
+		barLength := int(((hour.TempC-minTemp)/tempRange)*chartWidth) + 1
+		bar := strings.Repeat("█", barLength) + strings.Repeat(" ", chartWidth+1-barLength)
+
+		fmt.Printf("%s |%s| %5.1f%s 💧%3d%% 💨%5.1f %s\n",
+			data.Location.localTime(hour.Time).Format("15:04"), bar,
+			wa.Units.Temp(hour.TempC), wa.Units.TempSymbol(), hour.ChanceOfRain,
+			wa.Units.Speed(hour.WindKph), wa.Units.SpeedSymbol())
+	}
+
+	fmt.Printf("\nLegend: █ Temperature | 💧 Chance of rain | 💨 Wind\n")
+}
+
+func getMaxTemps(days []ForecastDay) []float64 {
 	var temps []float64
 	for _, day := range days {
-		temps = append(temps, day.Day.MaxTempC)
+		temps = append(temps, day.MaxTempC)
 	}
 	return temps
 }
 
-func getMinTemps(days []struct {
-	Date string `json:"date"`
-	Day  struct {
-		MaxTempC float64 `json:"maxtemp_c"`
-		MinTempC float64 `json:"mintemp_c"`
-		AvgTempC float64 `json:"avgtemp_c"`
-	} `json:"day"`
-}) []float64 {
+func getMinTemps(days []ForecastDay) []float64 {
 	var temps []float64
 	for _, day := range days {
-		temps = append(temps, day.Day.MinTempC)
+		temps = append(temps, day.MinTempC)
 	}
 	return temps
-}
\ No newline at end of file
+}
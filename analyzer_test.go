@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureStdout returns what fn prints.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- string(out)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func TestVisualizeFlatDay(t *testing.T) {
+	day := ForecastDay{Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), MaxTempC: 10, MinTempC: 10, AvgTempC: 10}
+	analyzer := &WeatherAnalyzer{Data: []Forecast{{Days: []ForecastDay{day}}}}
+
+	out := captureStdout(t, analyzer.VisualizeTemperatureTrends)
+	if !strings.Contains(out, "Fri: ❄") || !strings.Contains(out, "Max:10.0°C") {
+		t.Errorf("chart = %q", out)
+	}
+}
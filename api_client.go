@@ -1,54 +1,257 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math/rand"
+	"net"
 	"net/http"
+	"strconv"
+	"syscall"
 	"time"
 )
 
 const (
-	apiKey      = "your_api_key_here" // Replace with your OpenWeatherMap API key
-	baseURL     = "https://api.openweathermap.org/data/2.5/weather"
-	tempUnit    = "metric" // Use "imperial" for Fahrenheit
+	defaultMaxAttempts = 4
+	defaultBaseDelay   = 500 * time.Millisecond
+	defaultMaxDelay    = 30 * time.Second
+
+	// attemptTimeout bounds a single attempt even when the caller's context
+	// has no deadline, so a stalled provider can never hang the tool.
+	attemptTimeout = 30 * time.Second
 )
 
-func fetchCurrentWeather(city string) (WeatherData, error) {
-	url := fmt.Sprintf("%s?q=%s&appid=%s&units=%s", baseURL, city, apiKey, tempUnit)
-	
-	resp, err := http.Get(url)
+// apiError is returned when a provider answers with a non-200 status.
+type apiError struct {
+	StatusCode int
+	Status     string
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("API error: %s - %s", e.Status, e.Body)
+}
+
+// retryable reports whether the request may succeed if sent again.
+func (e *apiError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// apiClient is the HTTP layer shared by every provider. It retries 5xx, 429
+// and network errors with jittered exponential backoff, honours Retry-After
+// and stops as soon as the caller's context is cancelled.
+type apiClient struct {
+	provider    string
+	http        *http.Client
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+
+	// limiter and quota are optional; every attempt, including retries,
+	// waits for the limiter and counts against the quota.
+	limiter *tokenBucket
+	quota   *quotaTracker
+
+	// cache is optional; see responseCache.
+	cache *responseCache
+}
+
+func newAPIClient(provider string) *apiClient {
+	return &apiClient{
+		provider:    provider,
+		http:        &http.Client{Timeout: attemptTimeout},
+		maxAttempts: defaultMaxAttempts,
+		baseDelay:   defaultBaseDelay,
+		maxDelay:    defaultMaxDelay,
+	}
+}
+
+// getJSON performs a GET request and decodes the JSON response into v.
+// Errors never contain the API key from url.
+func (c *apiClient) getJSON(ctx context.Context, url string, v interface{}) error {
+	body, err := c.fetch(ctx, url)
+	if err != nil {
+		metrics.observeError(c.provider, err)
+		return redactURLCredentials(err, url)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("JSON decode failed: %v", err)
+	}
+	return nil
+}
+
+// fetch returns the response body for url, serving it from the cache when
+// a fresh copy exists and revalidating stale copies with the provider.
+func (c *apiClient) fetch(ctx context.Context, url string) ([]byte, error) {
+	var cached *cachedResponse
+	if c.cache != nil {
+		cached = c.cache.Load(c.provider, url)
+		if cached != nil && c.cache.fresh(cached) {
+			metrics.observeCache(c.provider, "hit")
+			return cached.Body, nil
+		}
+		if cached != nil {
+			metrics.observeCache(c.provider, "stale")
+		} else {
+			metrics.observeCache(c.provider, "miss")
+		}
+		if c.cache.offline {
+			return nil, fmt.Errorf("%w for %s", errOffline, stripCredentials(url))
+		}
+	}
+
+	var resp *cachedResponse
+	var err error
+	for attempt := 1; ; attempt++ {
+		resp, err = c.get(ctx, url, cached)
+		if err == nil || !isRetryable(ctx, err) || attempt >= c.maxAttempts {
+			break
+		}
+
+		delay := fullJitter(c.baseDelay, c.maxDelay, attempt)
+		var apiErr *apiError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			delay = min(apiErr.RetryAfter, c.maxDelay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w (gave up after %d attempts: %v)", ctx.Err(), attempt, err)
+		case <-timer.C:
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.cache != nil {
+		if err := c.cache.Store(resp); err != nil {
+			log.Printf("⚠️  Could not cache response: %v", err)
+		}
+	}
+	return resp.Body, nil
+}
+
+// get makes a single attempt. When cached is set the request is made
+// conditional and a 304 answer refreshes the cached copy.
+func (c *apiClient) get(ctx context.Context, url string, cached *cachedResponse) (*cachedResponse, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("waiting for rate limiter: %w", err)
+		}
+	}
+	if c.quota != nil {
+		if err := c.quota.Record(); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %v", err)
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	start := time.Now()
+	resp, err := c.http.Do(req)
 	if err != nil {
-		return WeatherData{}, fmt.Errorf("HTTP request failed: %v", err)
+		metrics.observeRequest(c.provider, 0, time.Since(start))
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return WeatherData{}, fmt.Errorf("API error: %s - %s", resp.Status, string(body))
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		metrics.observeRequest(c.provider, resp.StatusCode, time.Since(start))
+		refreshed := *cached
+		refreshed.FetchedAt = time.Now()
+		return &refreshed, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	metrics.observeRequest(c.provider, resp.StatusCode, time.Since(start))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	var weatherResp WeatherResponse
-	if err := json.NewDecoder(resp.Body).Decode(&weatherResp); err != nil {
-		return WeatherData{}, fmt.Errorf("JSON decode failed: %v", err)
+	if resp.StatusCode != http.StatusOK {
+		return nil, &apiError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
-	return WeatherData{
-		City:      weatherResp.Name,
-		Temp:      weatherResp.Main.Temp,
-		Humidity:  weatherResp.Main.Humidity,
-		Timestamp: time.Now(),
+	return &cachedResponse{
+		Provider:     c.provider,
+		URL:          stripCredentials(url),
+		FetchedAt:    time.Now(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Body:         body,
 	}, nil
 }
 
-func saveWeatherData(data []WeatherData) error {
-	file, err := os.Create("weather_data.json")
-	if err != nil {
-		return err
+// fullJitter returns a random delay in [0, base*2^(attempt-1)], capped at
+// max ("full jitter"). It spaces out the retries of both provider requests
+// and notification deliveries.
+func fullJitter(base, max time.Duration, attempt int) time.Duration {
+	ceiling := base << (attempt - 1)
+	if ceiling <= 0 || ceiling > max {
+		ceiling = max
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// isRetryable reports whether a failed attempt is worth repeating. Only the
+// caller's context ends the retries: an attempt that hit attemptTimeout
+// reports context.DeadlineExceeded too, but the next one may get through.
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.retryable()
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(data)
-}
\ No newline at end of file
+	// Timeouts and connection failures are worth another try; TLS,
+	// scheme and malformed URL errors fail the same way every time.
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// parseRetryAfter accepts both forms of the header: delay-seconds and an
+// HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
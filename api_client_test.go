@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// retryClient returns a client with short timeouts and no backoff delay.
+func retryClient() *apiClient {
+	client := newAPIClient("test")
+	client.http.Timeout = 50 * time.Millisecond
+	client.baseDelay = time.Millisecond
+	client.maxDelay = time.Millisecond
+	return client
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) < 3 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	var resp struct{}
+	if err := retryClient().getJSON(context.Background(), server.URL, &resp); err != nil {
+		t.Fatalf("getJSON: %v", err)
+	}
+	if hits.Load() != 3 {
+		t.Errorf("got %d hits, want 3", hits.Load())
+	}
+}
+
+func TestNoRetryClientErrors(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Error(w, "no such place", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	var resp struct{}
+	err := retryClient().getJSON(context.Background(), server.URL, &resp)
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("err = %v, want a 400 apiError", err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("got %d hits, want 1", hits.Load())
+	}
+}
+
+func TestRetryAfterTooManyRequests(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := retryClient()
+	client.maxDelay = 2 * time.Second
+	start := time.Now()
+	var resp struct{}
+	if err := client.getJSON(context.Background(), server.URL, &resp); err != nil {
+		t.Fatalf("getJSON: %v", err)
+	}
+	if elapsed := time.Since(start); hits.Load() != 2 || elapsed < time.Second {
+		t.Errorf("got %d hits after %v, want 2 after Retry-After", hits.Load(), elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("120"); got != 2*time.Minute {
+		t.Errorf("delay-seconds: got %v", got)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("HTTP date: got %v", got)
+	}
+	for _, value := range []string{"", "soon", "-5"} {
+		if got := parseRetryAfter(value); got != 0 {
+			t.Errorf("parseRetryAfter(%q) = %v, want 0", value, got)
+		}
+	}
+}
+
+func TestRetryStalledAttempt(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	var resp struct{ OK bool }
+	if err := retryClient().getJSON(context.Background(), server.URL, &resp); err != nil {
+		t.Fatalf("getJSON: %v", err)
+	}
+	if !resp.OK || hits.Load() != 2 {
+		t.Errorf("ok = %v after %d hits, want true after 2", resp.OK, hits.Load())
+	}
+}
+
+func TestNoRetryAfterCallerDeadline(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := retryClient()
+	client.http.Timeout = time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var resp struct{}
+	err := client.getJSON(ctx, server.URL, &resp)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("got %d hits, want 1", hits.Load())
+	}
+}
+
+func TestCancelDuringBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := retryClient()
+	client.baseDelay = time.Minute
+	client.maxDelay = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var resp struct{}
+	if err := client.getJSON(ctx, server.URL, &resp); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestNoRetryCertificateErrors(t *testing.T) {
+	var handshakes atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			handshakes.Add(1)
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	// The client does not trust the test server's certificate.
+	client := retryClient()
+	client.http.Timeout = time.Second
+	var resp struct{}
+	err := client.getJSON(context.Background(), server.URL, &resp)
+	var certErr *tls.CertificateVerificationError
+	if !errors.As(err, &certErr) {
+		t.Errorf("err = %v, want a certificate error", err)
+	}
+	if got := handshakes.Load(); got != 1 {
+		t.Errorf("got %d connections, want 1", got)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}, true},
+		{&url.Error{Op: "Get", URL: "https://example.com", Err: syscall.ECONNRESET}, true},
+		{&url.Error{Op: "Get", URL: "https://example.com", Err: context.DeadlineExceeded}, true},
+		{&url.Error{Op: "Get", URL: "ftp://example.com", Err: errors.New("unsupported protocol scheme \"ftp\"")}, false},
+		{&url.Error{Op: "Get", URL: "https://example.com", Err: x509.UnknownAuthorityError{}}, false},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(context.Background(), tt.err); got != tt.want {
+			t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestFullJitter(t *testing.T) {
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{5, time.Second},
+		{80, time.Second}, // the shift overflows
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := fullJitter(100*time.Millisecond, time.Second, tt.attempt); d < 0 || d > tt.ceiling {
+				t.Fatalf("attempt %d: delay %v outside [0, %v]", tt.attempt, d, tt.ceiling)
+			}
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Air quality indices are computed here from raw concentrations rather than
+// taken from providers, so every provider is rated the same way. Providers
+// report instantaneous concentrations while both indices are defined on
+// averages (one to 24 hours depending on the pollutant), so the result is
+// an estimate of the official index.
+
+// Molar volume of an ideal gas at 25 °C and 1 atm in litres, for converting
+// µg/m³ into the ppb and ppm the EPA breakpoints use.
+const molarVolume = 24.45
+
+// Molar masses in g/mol.
+const (
+	molarMassO3  = 48.00
+	molarMassNO2 = 46.01
+	molarMassSO2 = 64.07
+	molarMassCO  = 28.01
+)
+
+// epaCategories name the EPA index ranges, whose upper ends are
+// epaIndexBounds.
+var (
+	epaCategories  = []string{"Good", "Moderate", "Unhealthy for Sensitive Groups", "Unhealthy", "Very Unhealthy", "Hazardous"}
+	epaIndexBounds = []float64{50, 100, 150, 200, 300, 500}
+)
+
+// epaHealthAdvice follows the EPA's cautionary statements, by category.
+var epaHealthAdvice = []string{
+	"Air quality is good. Enjoy outdoor activities.",
+	"Unusually sensitive people should consider reducing prolonged or heavy outdoor exertion.",
+	"People with heart or lung disease, older adults and children should reduce prolonged or heavy outdoor exertion.",
+	"Everyone should reduce prolonged or heavy outdoor exertion; sensitive groups should avoid it.",
+	"Everyone should avoid prolonged or heavy outdoor exertion; sensitive groups should stay indoors.",
+	"Everyone should avoid all outdoor exertion and keep windows closed.",
+}
+
+// epaBreakpoints are the upper concentration of each category, in the unit
+// and precision the EPA truncates to: µg/m³ for particles, ppb for O3, NO2
+// and SO2, and ppm for CO. PM2.5 uses the 2024 breakpoints. The 8-hour O3
+// table ends at "Very Unhealthy"; see epaO3OneHour for higher values.
+var epaBreakpoints = map[string][]float64{
+	"pm2_5": {9.0, 35.4, 55.4, 125.4, 225.4, 325.4},
+	"pm10":  {54, 154, 254, 354, 424, 604},
+	"o3":    {54, 70, 85, 105, 200},
+	"no2":   {53, 100, 360, 649, 1249, 2049},
+	"so2":   {35, 75, 185, 304, 604, 1004},
+	"co":    {4.4, 9.4, 12.4, 15.4, 30.4, 50.4},
+}
+
+// epaO3OneHour is the 1-hour O3 table in ppb. It starts at "Unhealthy for
+// Sensitive Groups" from epaO3OneHourStart and is the only one above 200
+// ppb; where both tables apply the EPA reports the higher index.
+var epaO3OneHour = []float64{164, 204, 404, 604}
+
+const epaO3OneHourStart = 125
+
+// epaPrecision is the step each concentration is truncated to.
+var epaPrecision = map[string]float64{
+	"pm2_5": 0.1, "pm10": 1, "o3": 1, "no2": 1, "so2": 1, "co": 0.1,
+}
+
+// defraBandStarts are the lowest concentration, in µg/m³, of bands 2 to 10
+// of the UK Daily Air Quality Index. Carbon monoxide is not part of it.
+var defraBandStarts = map[string][]float64{
+	"o3":    {34, 67, 101, 121, 141, 161, 188, 214, 241},
+	"no2":   {68, 135, 201, 268, 335, 401, 468, 535, 601},
+	"so2":   {89, 178, 267, 355, 444, 533, 711, 888, 1065},
+	"pm2_5": {12, 24, 36, 42, 48, 54, 59, 65, 71},
+	"pm10":  {17, 34, 51, 59, 67, 76, 84, 92, 101},
+}
+
+// pollutantNames are how pollutants are shown to people, in display order.
+var pollutantNames = []struct{ key, name string }{
+	{"pm2_5", "PM2.5"}, {"pm10", "PM10"}, {"o3", "O₃"}, {"no2", "NO₂"}, {"so2", "SO₂"}, {"co", "CO"},
+}
+
+// concentrations returns the pollutants keyed as in the breakpoint tables,
+// in µg/m³.
+func (a AirQuality) concentrations() map[string]float64 {
+	return map[string]float64{
+		"pm2_5": a.PM25Ug, "pm10": a.PM10Ug, "o3": a.O3Ug, "no2": a.NO2Ug, "so2": a.SO2Ug, "co": a.COUg,
+	}
+}
+
+// epaConcentration converts a concentration in µg/m³ into the unit of the
+// pollutant's EPA breakpoints.
+func epaConcentration(pollutant string, ug float64) float64 {
+	switch pollutant {
+	case "o3":
+		return ug * molarVolume / molarMassO3
+	case "no2":
+		return ug * molarVolume / molarMassNO2
+	case "so2":
+		return ug * molarVolume / molarMassSO2
+	case "co":
+		return ug * molarVolume / molarMassCO / 1000
+	}
+	return ug
+}
+
+// epaSubIndex returns the EPA index for one pollutant, capped at 500.
+func epaSubIndex(pollutant string, ug float64) int {
+	step := epaPrecision[pollutant]
+	c := math.Floor(epaConcentration(pollutant, ug)/step+1e-9) * step
+
+	index, ok := epaScale(c, step, 0, 0, epaBreakpoints[pollutant])
+	if pollutant == "o3" && c >= epaO3OneHourStart {
+		oneHour, _ := epaScale(c, step, epaO3OneHourStart, 2, epaO3OneHour)
+		if !ok || oneHour > index {
+			index = oneHour
+		}
+	}
+	return index
+}
+
+// epaScale interpolates c linearly within its category of a breakpoint
+// table whose first category is first and starts at lowC. ok is false when
+// c is above the table, and the index is then 500.
+func epaScale(c, step, lowC float64, first int, breakpoints []float64) (index int, ok bool) {
+	lowI := 0.0
+	if first > 0 {
+		lowI = epaIndexBounds[first-1] + 1
+	}
+	for i, highC := range breakpoints {
+		highI := epaIndexBounds[first+i]
+		if c <= highC+1e-9 {
+			return int(math.Round((highI-lowI)/(highC-lowC)*(c-lowC) + lowI)), true
+		}
+		lowC, lowI = highC+step, highI+1
+	}
+	return 500, false
+}
+
+// epaCategory returns the index of the category index falls in.
+func epaCategory(index int) int {
+	for i, bound := range epaIndexBounds {
+		if float64(index) <= bound {
+			return i
+		}
+	}
+	return len(epaCategories) - 1
+}
+
+// defraSubIndex returns the UK band, 1 to 10, for one pollutant.
+func defraSubIndex(pollutant string, ug float64) int {
+	band := 1
+	for _, start := range defraBandStarts[pollutant] {
+		if math.Round(ug) >= start {
+			band++
+		}
+	}
+	return band
+}
+
+// defraBandName returns the name of the UK band group: Low, Moderate, High
+// or Very High.
+func defraBandName(index int) string {
+	switch {
+	case index <= 3:
+		return "Low"
+	case index <= 6:
+		return "Moderate"
+	case index <= 9:
+		return "High"
+	}
+	return "Very High"
+}
+
+// AirQualityIndex rates a set of concentrations on the US EPA and UK DEFRA
+// scales. Each index is that of the worst pollutant.
+type AirQualityIndex struct {
+	USEPA         int    `json:"us_epa"`
+	USEPACategory string `json:"us_epa_category"`
+	UKDEFRA       int    `json:"uk_defra"`
+	UKDEFRABand   string `json:"uk_defra_band"`
+	Dominant      string `json:"dominant_pollutant"` // by EPA sub-index
+	HealthAdvice  string `json:"health_advice"`
+}
+
+// Index rates the concentrations.
+func (a AirQuality) Index() AirQualityIndex {
+	concentrations := a.concentrations()
+
+	var index AirQualityIndex
+	for _, pollutant := range pollutantNames {
+		ug := concentrations[pollutant.key]
+		if epa := epaSubIndex(pollutant.key, ug); epa > index.USEPA || index.Dominant == "" {
+			index.USEPA = epa
+			index.Dominant = pollutant.name
+		}
+		if _, ok := defraBandStarts[pollutant.key]; ok {
+			index.UKDEFRA = max(index.UKDEFRA, defraSubIndex(pollutant.key, ug))
+		}
+	}
+	category := epaCategory(index.USEPA)
+	index.USEPACategory = epaCategories[category]
+	index.UKDEFRABand = defraBandName(index.UKDEFRA)
+	index.HealthAdvice = epaHealthAdvice[category]
+	return index
+}
+
+// formatConcentrations lists the pollutants on one line.
+func (a AirQuality) formatConcentrations() string {
+	concentrations := a.concentrations()
+	parts := make([]string, len(pollutantNames))
+	for i, pollutant := range pollutantNames {
+		parts[i] = fmt.Sprintf("%s %.1f", pollutant.name, concentrations[pollutant.key])
+	}
+	return strings.Join(parts, " · ") + " µg/m³"
+}
+
+// displayAirQuality prints the air quality section of the current weather.
+func displayAirQuality(airQuality *AirQuality) {
+	if airQuality == nil {
+		return
+	}
+	index := airQuality.Index()
+	fmt.Printf("🌫️  Air Quality: US AQI %d (%s), UK DAQI %d (%s), mainly %s\n",
+		index.USEPA, index.USEPACategory, index.UKDEFRA, index.UKDEFRABand, index.Dominant)
+	fmt.Printf("   %s\n", airQuality.formatConcentrations())
+	fmt.Printf("🩺 %s\n", index.HealthAdvice)
+}
+
+// AirQualitySummary describes the air quality over a set of readings.
+type AirQualitySummary struct {
+	Readings         int    `json:"readings"` // readings with air quality data
+	AverageUSEPA     int    `json:"average_us_epa"`
+	MaxUSEPA         int    `json:"max_us_epa"`
+	MaxUSEPACategory string `json:"max_us_epa_category"`
+	MaxUKDEFRA       int    `json:"max_uk_defra"`
+	MaxUKDEFRABand   string `json:"max_uk_defra_band"`
+	HealthAdvice     string `json:"health_advice"` // for the worst reading
+}
+
+// summarizeAirQuality returns nil when no reading has air quality data.
+func summarizeAirQuality(data []WeatherData) *AirQualitySummary {
+	var summary AirQualitySummary
+	var total int
+	for _, reading := range data {
+		if reading.AirQuality == nil {
+			continue
+		}
+		index := reading.AirQuality.Index()
+		summary.Readings++
+		total += index.USEPA
+		if summary.Readings == 1 || index.USEPA > summary.MaxUSEPA {
+			summary.MaxUSEPA = index.USEPA
+			summary.MaxUSEPACategory = index.USEPACategory
+			summary.HealthAdvice = index.HealthAdvice
+		}
+		summary.MaxUKDEFRA = max(summary.MaxUKDEFRA, index.UKDEFRA)
+	}
+	if summary.Readings == 0 {
+		return nil
+	}
+	summary.AverageUSEPA = int(math.Round(float64(total) / float64(summary.Readings)))
+	summary.MaxUKDEFRABand = defraBandName(summary.MaxUKDEFRA)
+	return &summary
+}
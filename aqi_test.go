@@ -0,0 +1,89 @@
+package main
+
+import "testing"
+
+// ppbToUg converts an ozone concentration in ppb into µg/m³.
+func ppbToUg(ppb float64) float64 {
+	return ppb * molarMassO3 / molarVolume
+}
+
+func TestEPASubIndexCategoryEdges(t *testing.T) {
+	tests := []struct {
+		pollutant string
+		ug        float64
+		want      int
+	}{
+		{"pm2_5", 0, 0},
+		{"pm2_5", 9.0, 50},
+		{"pm2_5", 9.1, 51},
+		{"pm2_5", 35.4, 100},
+		{"pm2_5", 35.5, 101},
+		{"pm2_5", 55.4, 150},
+		{"pm2_5", 55.5, 151},
+		{"pm2_5", 125.4, 200},
+		{"pm2_5", 125.5, 201},
+		{"pm2_5", 225.4, 300},
+		{"pm2_5", 225.5, 301},
+		{"pm2_5", 325.4, 500},
+		{"pm2_5", 400, 500},
+		{"pm10", 54, 50},
+		{"pm10", 55, 51},
+		{"pm10", 154, 100},
+		{"pm10", 155, 101},
+
+		// The 8-hour ozone table.
+		{"o3", ppbToUg(54), 50},
+		{"o3", ppbToUg(55), 51},
+		{"o3", ppbToUg(70), 100},
+		{"o3", ppbToUg(71), 101},
+		{"o3", ppbToUg(85), 150},
+		{"o3", ppbToUg(86), 151},
+		{"o3", ppbToUg(105), 200},
+		{"o3", ppbToUg(106), 201},
+		{"o3", ppbToUg(200), 300},
+
+		// Above 200 ppb only the 1-hour table applies.
+		{"o3", ppbToUg(201), 196},
+		{"o3", ppbToUg(205), 201},
+		{"o3", ppbToUg(300), 248},
+		{"o3", ppbToUg(404), 300},
+		{"o3", ppbToUg(405), 301},
+		{"o3", ppbToUg(504), 400},
+		{"o3", ppbToUg(505), 401},
+		{"o3", ppbToUg(604), 500},
+		{"o3", ppbToUg(700), 500},
+	}
+	for _, tt := range tests {
+		if got := epaSubIndex(tt.pollutant, tt.ug); got != tt.want {
+			t.Errorf("epaSubIndex(%s, %g) = %d, want %d", tt.pollutant, tt.ug, got, tt.want)
+		}
+	}
+}
+
+func TestEPAOzoneCategories(t *testing.T) {
+	tests := []struct {
+		ppb  float64
+		want string
+	}{
+		{54, "Good"},
+		{70, "Moderate"},
+		{200, "Very Unhealthy"},
+		{300, "Very Unhealthy"},
+		{404, "Very Unhealthy"},
+		{405, "Hazardous"},
+	}
+	for _, tt := range tests {
+		index := AirQuality{O3Ug: ppbToUg(tt.ppb)}.Index()
+		if index.USEPACategory != tt.want || index.Dominant != "O₃" {
+			t.Errorf("%g ppb: %s (%d) from %s, want %s", tt.ppb, index.USEPACategory, index.USEPA, index.Dominant, tt.want)
+		}
+	}
+}
+
+func TestEPACategoryEdges(t *testing.T) {
+	for index, want := range map[int]int{0: 0, 50: 0, 51: 1, 100: 1, 101: 2, 150: 2, 151: 3, 200: 3, 201: 4, 300: 4, 301: 5, 500: 5} {
+		if got := epaCategory(index); got != want {
+			t.Errorf("epaCategory(%d) = %d, want %d", index, got, want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultCacheTTL = 10 * time.Minute
+
+// errOffline is returned in offline mode when nothing is cached for a request.
+var errOffline = errors.New("offline and no cached response")
+
+// credentialParams are query parameters that carry API keys; they are left
+// out of cache keys so rotating a key does not invalidate the cache.
+var credentialParams = []string{"key", "appid", "apikey", "api_key"}
+
+// cachedResponse is a provider response as stored on disk.
+type cachedResponse struct {
+	Provider     string          `json:"provider"`
+	URL          string          `json:"url"`
+	FetchedAt    time.Time       `json:"fetched_at"`
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"last_modified,omitempty"`
+	Body         json.RawMessage `json:"body"`
+}
+
+// responseCache keeps provider responses on disk, one file per
+// provider+endpoint+location, so repeated runs do not spend quota on data
+// that was just fetched.
+type responseCache struct {
+	dir     string
+	ttl     time.Duration
+	offline bool
+}
+
+// newResponseCache returns a cache in dir, defaulting to the user's cache
+// directory.
+func newResponseCache(dir string, ttl time.Duration, offline bool) (*responseCache, error) {
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("could not find cache directory: %v", err)
+		}
+		dir = filepath.Join(base, "weather-analyzer")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create cache directory: %v", err)
+	}
+	return &responseCache{dir: dir, ttl: ttl, offline: offline}, nil
+}
+
+// configuredCache builds the cache described by the config.
+func configuredCache(config Config) (*responseCache, error) {
+	ttl := defaultCacheTTL
+	if config.CacheTTL != "" {
+		parsed, err := time.ParseDuration(config.CacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache_ttl %q: %v", config.CacheTTL, err)
+		}
+		ttl = parsed
+	}
+	return newResponseCache(config.CacheDir, ttl, config.Offline)
+}
+
+// fresh reports whether a response can be served without asking the provider.
+func (c *responseCache) fresh(resp *cachedResponse) bool {
+	return c.offline || time.Since(resp.FetchedAt) < c.ttl
+}
+
+// Load returns the cached response for a request, or nil.
+func (c *responseCache) Load(provider, rawURL string) *cachedResponse {
+	data, err := os.ReadFile(c.path(provider, rawURL))
+	if err != nil {
+		return nil
+	}
+
+	var resp cachedResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil // treat a corrupt entry as a miss
+	}
+	return &resp
+}
+
+// Store writes a response, replacing the file atomically so concurrent
+// readers never see a partial entry.
+func (c *responseCache) Store(resp *cachedResponse) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	path := c.path(resp.Provider, resp.URL)
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *responseCache) path(provider, rawURL string) string {
+	sum := sha256.Sum256([]byte(provider + "\n" + stripCredentials(rawURL)))
+	return filepath.Join(c.dir, provider+"-"+hex.EncodeToString(sum[:12])+".json")
+}
+
+// stripCredentials removes API keys from a URL.
+func stripCredentials(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	query := parsed.Query()
+	for _, param := range credentialParams {
+		query.Del(param)
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
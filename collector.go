@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const defaultCollectInterval = 15 * time.Minute
+
+// Collector polls a set of locations on a fixed interval and stores every
+// reading it gets back.
+type Collector struct {
+	providers   *providerSet
+	resolver    *LocationResolver
+	store       *WeatherStore
+	locations   []string
+	interval    time.Duration
+	concurrency int
+	timeout     time.Duration
+	logger      *log.Logger
+
+	// alerts is optional; when set the alert rules are checked after
+	// every poll.
+	alerts *alertChecker
+
+	// failures counts consecutive failed polls per location
+	failures map[string]int
+}
+
+func newCollector(providers *providerSet, store *WeatherStore, locations []string, interval time.Duration) *Collector {
+	return &Collector{
+		providers:   providers,
+		store:       store,
+		locations:   locations,
+		interval:    interval,
+		concurrency: defaultConcurrency,
+		timeout:     defaultRequestTimeout,
+		logger:      log.New(os.Stderr, "collect: ", log.LstdFlags),
+		failures:    make(map[string]int),
+	}
+}
+
+// Run polls immediately and then once per interval until ctx is cancelled.
+// Failed fetches are logged and retried on the next tick.
+func (c *Collector) Run(ctx context.Context) error {
+	var names []string
+	for _, location := range c.locations {
+		// A provider that cannot be built fails the location's polls instead.
+		if provider, err := c.providers.provider(location); err == nil && !slices.Contains(names, provider.Name()) {
+			names = append(names, provider.Name())
+		}
+	}
+	c.logger.Printf("polling %d locations every %s via %s", len(c.locations), c.interval, strings.Join(names, ", "))
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			c.logger.Printf("shutting down")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Collector) poll(ctx context.Context) {
+	for _, result := range fetchMany(ctx, c.providers, c.resolver, c.locations, c.concurrency, c.timeout) {
+		if ctx.Err() != nil {
+			return
+		}
+
+		location, weather, err := result.Location, result.Data, result.Err
+		if err == nil {
+			metrics.observeReading(weather)
+			err = c.store.Save(*weather)
+		}
+		if err != nil {
+			c.failures[location]++
+			c.logger.Printf("%s: %v (%d consecutive failures)", location, err, c.failures[location])
+			continue
+		}
+
+		if c.failures[location] > 0 {
+			c.logger.Printf("%s: recovered after %d failures", location, c.failures[location])
+		}
+		c.failures[location] = 0
+		c.logger.Printf("%s: %s, %d%% humidity", location, c.providers.units(location).FormatTemp(weather.TempC), weather.Humidity)
+	}
+
+	if c.alerts != nil {
+		events, err := c.alerts.Check(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			c.logger.Printf("alerts: %v", err)
+		}
+		for _, event := range events {
+			c.logger.Print(formatAlertEvent(event))
+		}
+	}
+}
+
+// runCollect implements the "collect" command.
+func runCollect(ctx context.Context, args []string) error {
+	config, _ := loadConfig()
+	concurrency, timeout, err := fetchSettings(config)
+	if err != nil {
+		return err
+	}
+
+	interval := defaultCollectInterval
+	if config.CollectInterval != "" {
+		parsed, err := time.ParseDuration(config.CollectInterval)
+		if err != nil {
+			return fmt.Errorf("invalid collect_interval %q: %v", config.CollectInterval, err)
+		}
+		interval = parsed
+	}
+
+	flags := newCommandFlags("collect")
+	flags.DurationVar(&interval, "interval", interval, "time between polls")
+	flags.IntVar(&concurrency, "concurrency", concurrency, "maximum simultaneous requests")
+	flags.DurationVar(&timeout, "timeout", timeout, "timeout for each location")
+	metricsAddr := flags.String("metrics-addr", "", "serve Prometheus metrics on this host:port while collecting")
+	flags.Parse(args)
+
+	locations := config.CollectLocations
+	if flags.NArg() > 0 {
+		locations = flags.Args()
+	}
+	if len(locations) == 0 {
+		return usageError("no locations to collect; pass them as arguments or set collect_locations")
+	}
+	if interval <= 0 {
+		return usageError("interval must be positive, got %s", interval)
+	}
+
+	provider, err := newProvider(config)
+	if err != nil {
+		return err
+	}
+	resolver, err := newLocationResolver(config, false)
+	if err != nil {
+		return err
+	}
+	store, err := openConfiguredStore(config)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if *metricsAddr != "" {
+		if err := serveMetrics(ctx, *metricsAddr); err != nil {
+			return err
+		}
+	}
+
+	collector := newCollector(newProviderSet(config, provider), store, locations, interval)
+	collector.resolver = resolver
+	collector.concurrency = concurrency
+	collector.timeout = timeout
+	if len(config.Alerts) > 0 {
+		checker, err := newAlertChecker(config, provider, resolver)
+		if err != nil {
+			return err
+		}
+		defer checker.Close()
+		collector.alerts = checker
+	}
+	return collector.Run(ctx)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// runCurrent implements the "current" command.
+func runCurrent(ctx context.Context, args []string) error {
+	flags := newCommandFlags("current")
+	output := addOutputFlag(flags)
+	flags.Parse(args)
+	if err := checkOutputFormat(*output); err != nil {
+		return err
+	}
+
+	if *output == outputText {
+		printBanner()
+	}
+	location := locationArg(flags.Args())
+
+	// A saved location may bring its own provider and units
+	config, _ := loadConfig()
+	config = withSavedLocation(config, location)
+	units, _ := parseUnits(config.Units) // validated by loadConfig
+
+	current, err := fetchCurrentWeather(ctx, config, location)
+	if err != nil {
+		return fmt.Errorf("could not fetch current weather: %w", err)
+	}
+
+	storeReading(&current.Current)
+
+	if *output != outputText {
+		return writeDocument(os.Stdout, *output, newCurrentDocument(&current.Current, current.Alerts))
+	}
+	analyzer := &WeatherAnalyzer{Data: []Forecast{*current}, Units: units}
+	analyzer.DisplayCurrentWeather()
+	return nil
+}
+
+// runForecast implements the "forecast" command.
+func runForecast(ctx context.Context, args []string) error {
+	flags := newCommandFlags("forecast")
+	days := flags.Int("days", 7, "number of days to forecast")
+	hourly := flags.Bool("hourly", false, "chart today's forecast hour by hour")
+	output := addOutputFlag(flags)
+	flags.Parse(args)
+
+	if *days < 1 {
+		return usageError("-days must be at least 1, got %d", *days)
+	}
+	if err := checkOutputFormat(*output); err != nil {
+		return err
+	}
+
+	if *output == outputText {
+		printBanner()
+	}
+	location := locationArg(flags.Args())
+
+	config, _ := loadConfig()
+	config = withSavedLocation(config, location)
+	units, _ := parseUnits(config.Units) // validated by loadConfig
+
+	forecast, err := fetchWeatherData(ctx, config, location, *days)
+	if err != nil {
+		return fmt.Errorf("could not fetch weather data: %w", err)
+	}
+	storeReading(&forecast.Current)
+
+	if *output != outputText {
+		return writeDocument(os.Stdout, *output, newForecastDocument(forecast))
+	}
+	analyzer := &WeatherAnalyzer{Data: []Forecast{*forecast}, ChartMode: chartModeDaily, Units: units}
+	if *hourly {
+		analyzer.ChartMode = chartModeHourly
+	}
+	analyzer.DisplayCurrentWeather()
+	analyzer.AnalyzeTemperatureTrends()
+	analyzer.VisualizeTemperatureTrends()
+	return nil
+}
+
+// runHistory implements the "history" command: the provider's record of a
+// past day, as opposed to "analyze", which looks at stored readings.
+func runHistory(ctx context.Context, args []string) error {
+	yesterday := time.Now().AddDate(0, 0, -1).Format(time.DateOnly)
+
+	flags := newCommandFlags("history")
+	dateFlag := flags.String("date", yesterday, "day to show, as YYYY-MM-DD")
+	output := addOutputFlag(flags)
+	flags.Parse(args)
+	if err := checkOutputFormat(*output); err != nil {
+		return err
+	}
+
+	date, err := time.Parse(time.DateOnly, *dateFlag)
+	if err != nil {
+		return usageError("invalid -date %q; use YYYY-MM-DD", *dateFlag)
+	}
+	if date.After(time.Now()) {
+		return usageError("-date %s is in the future; use forecast instead", *dateFlag)
+	}
+
+	if *output == outputText {
+		printBanner()
+	}
+	location := locationArg(flags.Args())
+
+	config, _ := loadConfig()
+	config = withSavedLocation(config, location)
+	units, _ := parseUnits(config.Units) // validated by loadConfig
+
+	provider, err := newProvider(config)
+	if err != nil {
+		return err
+	}
+	_, timeout, err := fetchSettings(config)
+	if err != nil {
+		return err
+	}
+	loc, err := resolveLocation(ctx, config, location)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	history, err := provider.History(ctx, loc.Query(), date)
+	if err != nil {
+		return fmt.Errorf("could not fetch history for %s: %w", *dateFlag, err)
+	}
+	history.Location = loc.canonical(history.Location)
+	if len(history.Days) == 0 {
+		return fmt.Errorf("no history available for %s on %s", history.Location.DisplayName(), *dateFlag)
+	}
+
+	day := history.Days[0]
+	if *output != outputText {
+		return writeDocument(os.Stdout, *output, newHistoryDocument(history.Location, day))
+	}
+	fmt.Printf("\n📜 Weather in %s on %s\n", history.Location.DisplayName(), day.Date.Format("Monday, 2 January 2006"))
+	fmt.Println("====================================")
+	fmt.Printf("☁️  Condition: %s\n", day.Condition)
+	fmt.Printf("🌡️  Max: %s, Min: %s, Avg: %s\n",
+		units.FormatTemp(day.MaxTempC), units.FormatTemp(day.MinTempC), units.FormatTemp(day.AvgTempC))
+
+	analyzer := &WeatherAnalyzer{Data: []Forecast{*history}, ChartMode: chartModeHourly, Units: units}
+	analyzer.VisualizeTemperatureTrends()
+	return nil
+}
+
+// runAnalyze implements the "analyze" command over readings stored by
+// earlier runs.
+func runAnalyze(ctx context.Context, args []string) error {
+	flags := newCommandFlags("analyze")
+	period := flags.Duration("period", 7*24*time.Hour, "how far back to look (e.g. 24h, 168h)")
+	chart := flags.String("chart", "temp", "metric to chart: "+strings.Join(chartMetrics(), ", "))
+	output := addOutputFlag(flags)
+	flags.Parse(args)
+
+	if *period <= 0 {
+		return usageError("-period must be positive, got %s", *period)
+	}
+	if !slices.Contains(chartMetrics(), *chart) {
+		return usageError("unknown -chart metric %q; use one of %s", *chart, strings.Join(chartMetrics(), ", "))
+	}
+	if err := checkOutputFormat(*output); err != nil {
+		return err
+	}
+
+	if *output == outputText {
+		printBanner()
+	}
+	location := locationArg(flags.Args())
+
+	config, _ := loadConfig()
+	config = withSavedLocation(config, location)
+	units, _ := parseUnits(config.Units) // validated by loadConfig
+
+	loc, err := analysisLocation(ctx, config, location)
+	if err != nil {
+		return err
+	}
+	if *output == outputText {
+		return analyzeAndVisualize(loc, *period, *chart, units)
+	}
+
+	readings, err := loadWeatherData(loc, *period)
+	if err != nil {
+		return fmt.Errorf("could not load weather data: %v", err)
+	}
+	return writeDocument(os.Stdout, *output, newAnalysisDocument(loc, *period, readings))
+}
+
+// analysisLocation finds the place whose stored readings are analyzed: a
+// saved location, or the one place already stored under that name, without
+// touching the network. Other names go to the geocoder, so "analyze
+// -offline" works for every place readings were stored for.
+func analysisLocation(ctx context.Context, config Config, location string) (Location, error) {
+	if saved, ok := savedLocation(config.Locations, location); ok {
+		return saved.location(), nil
+	}
+	stored, err := storedLocations(strings.TrimSpace(location))
+	if err != nil {
+		return Location{}, fmt.Errorf("could not load weather data: %v", err)
+	}
+	if len(stored) == 1 {
+		return stored[0], nil
+	}
+
+	loc, err := resolveLocation(ctx, config, location)
+	if err != nil && len(stored) > 1 {
+		names := make([]string, len(stored))
+		for i, s := range stored {
+			names[i] = s.DisplayName()
+		}
+		return Location{}, fmt.Errorf("%w; readings are stored for %s, so name one by its coordinates or a saved location",
+			err, strings.Join(names, " and "))
+	}
+	return loc, err
+}
+
+// runImport implements the "import" command.
+func runImport(ctx context.Context, args []string) error {
+	flags := newCommandFlags("import")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return usageError("usage: import <file>")
+	}
+	path := flags.Arg(0)
+
+	count, err := importWeatherDataFile(path)
+	if err != nil {
+		return fmt.Errorf("could not import %s: %w", path, err)
+	}
+	fmt.Printf("Imported %d readings from %s\n", count, path)
+	return nil
+}
+
+// storeReading keeps a reading so history builds up across runs. Failures
+// only warn, on stderr so they never mix with structured output.
+func storeReading(weather *WeatherData) {
+	metrics.observeReading(weather)
+	if err := storeWeatherData(weather); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not store reading: %v\n", err)
+	}
+}
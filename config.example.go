@@ -1,9 +0,0 @@
-package main
-
-// Configuration file example
-// Copy this to config.go and add your actual API key
-
-const (
-	// Get your free API key from https://www.weatherapi.com/
-	apiKey = "YOUR_API_KEY_HERE"
-)
\ No newline at end of file
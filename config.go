@@ -1,44 +1,514 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// configFileName is the project config file, read from the working
+	// directory.
+	configFileName = "config.json"
+
+	systemConfigPath = "/etc/weather-analyzer/config.json"
+
+	// envPrefix starts every environment variable that overrides the config.
+	envPrefix = "WEATHER_"
 )
 
 type Config struct {
-	APIKey string `json:"api_key"`
-	Units  string `json:"units"`
-	City   string `json:"default_city"`
+	// APIKey and APIKeys hold keys inline. Prefer CredentialsFile or
+	// CredentialsCommand so keys stay out of files that get committed.
+	APIKey  string            `json:"api_key,omitempty"`
+	APIKeys map[string]string `json:"api_keys,omitempty"`
+
+	// CredentialsFile holds API keys (see readCredentialsFile) and
+	// CredentialsCommand prints the key for the provider it is given.
+	CredentialsFile    string `json:"credentials_file,omitempty"`
+	CredentialsCommand string `json:"credentials_command,omitempty"`
+
+	Provider string `json:"provider"`
+	City     string `json:"default_city"`
+
+	// Units is a unit system (metric, imperial, si, uk) optionally followed
+	// by overrides, e.g. "metric,wind=mph".
+	Units string `json:"units"`
+
+	// Locations are named places usable wherever a location is accepted.
+	Locations []SavedLocation `json:"locations,omitempty"`
+
+	// DatabasePath is the SQLite file readings are stored in and
+	// RetentionDays how long they are kept; zero keeps them forever.
+	DatabasePath  string `json:"database_path,omitempty"`
+	RetentionDays int    `json:"retention_days,omitempty"`
+
+	// CollectLocations and CollectInterval drive the collect mode; the
+	// interval is a Go duration such as "15m".
+	CollectLocations []string `json:"collect_locations,omitempty"`
+	CollectInterval  string   `json:"collect_interval,omitempty"`
+
+	// Concurrency bounds simultaneous requests when fetching several
+	// locations and RequestTimeout limits each one (a Go duration).
+	Concurrency    int    `json:"concurrency,omitempty"`
+	RequestTimeout string `json:"request_timeout,omitempty"`
+
+	// MaxRetries is how many times a failed request is retried.
+	MaxRetries int `json:"max_retries,omitempty"`
+
+	// Alerts are threshold rules checked by "alerts check" and collect.
+	Alerts []AlertRule `json:"alerts,omitempty"`
+
+	// Notifications are where alert events are delivered besides the
+	// terminal or log.
+	Notifications []NotificationSink `json:"notifications,omitempty"`
+
+	// ListenAddr is the host:port the serve command listens on.
+	ListenAddr string `json:"listen_addr,omitempty"`
+
+	// Quotas overrides the built-in per-provider rate and call limits.
+	Quotas map[string]QuotaConfig `json:"quotas,omitempty"`
+
+	// CacheDir and CacheTTL control the response cache; Offline serves
+	// only cached responses.
+	CacheDir string `json:"cache_dir,omitempty"`
+	CacheTTL string `json:"cache_ttl,omitempty"`
+	Offline  bool   `json:"offline,omitempty"`
+
+	// RecordDir saves every raw provider response as a fixture and
+	// ReplayDir serves fixtures instead of using the network. Both are
+	// only set from the command line.
+	RecordDir string `json:"-"`
+	ReplayDir string `json:"-"`
+}
+
+// cliFlags holds global command line flags, the highest config layer.
+var cliFlags struct {
+	provider  string
+	units     string
+	offline   bool
+	recordDir string
+	replayDir string
+
+	// nonInteractive disables every prompt, for scripts and cron jobs.
+	nonInteractive bool
+}
+
+// defaultConfig is the lowest config layer.
+func defaultConfig() Config {
+	return Config{
+		City:            "London",
+		Units:           "metric",
+		DatabasePath:    defaultDatabasePath,
+		CollectInterval: shortDuration(defaultCollectInterval),
+		Concurrency:     defaultConcurrency,
+		RequestTimeout:  shortDuration(defaultRequestTimeout),
+		MaxRetries:      defaultMaxAttempts - 1,
+		CacheTTL:        shortDuration(defaultCacheTTL),
+		ListenAddr:      defaultListenAddr,
+	}
+}
+
+// configFiles returns the config files in order of increasing precedence:
+// system-wide, per user (honoring XDG_CONFIG_HOME) and per project.
+func configFiles() []string {
+	files := []string{systemConfigPath}
+	if dir, err := os.UserConfigDir(); err == nil {
+		files = append(files, filepath.Join(dir, "weather-analyzer", configFileName))
+	}
+	return append(files, configFileName)
+}
+
+// configSources records where the loaded config came from.
+type configSources struct {
+	Files []string
+	Env   []string
+	Flags []string
 }
 
+var (
+	configOnce      sync.Once
+	loadedConfig    Config
+	loadedSources   configSources
+	loadedConfigErr error
+)
+
+// loadConfig returns the effective configuration: defaults, then each config
+// file, then WEATHER_* environment variables, then command line flags, each
+// layer overriding the ones before it. It is read and validated once per
+// process.
 func loadConfig() (Config, error) {
-	var config Config
-	
-	// Check if config file exists
-	if _, err := os.Stat("config.json"); os.IsNotExist(err) {
-		return config, err
+	configOnce.Do(func() {
+		loadedConfig, loadedSources, loadedConfigErr = buildConfig(configFiles(), os.Getenv)
+	})
+	return loadedConfig, loadedConfigErr
+}
+
+func buildConfig(files []string, getenv func(string) string) (Config, configSources, error) {
+	config := defaultConfig()
+	var sources configSources
+
+	for _, path := range files {
+		found, err := readConfigLayer(path, &config)
+		if err != nil {
+			return config, sources, err
+		}
+		if found {
+			sources.Files = append(sources.Files, path)
+		}
 	}
 
-	file, err := os.ReadFile("config.json")
+	env, err := applyEnv(&config, getenv)
+	sources.Env = env
+	if err != nil {
+		return config, sources, err
+	}
+
+	sources.Flags = applyFlags(&config)
+
+	return config, sources, validateConfig(config)
+}
+
+// readConfigLayer merges the file at path into config; settings the file
+// does not mention, or sets to "" or null, keep their earlier value. A
+// missing file is skipped.
+func readConfigLayer(path string, config *Config) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
 	if err != nil {
-		return config, err
+		return false, err
+	}
+
+	var settings map[string]json.RawMessage
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return false, fmt.Errorf("%s: %v", path, err)
+	}
+	for key, value := range settings {
+		if string(value) == `""` || string(value) == "null" {
+			delete(settings, key)
+		}
+	}
+	if data, err = json.Marshal(settings); err != nil {
+		return false, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return false, fmt.Errorf("%s: %v", path, err)
+	}
+	return true, nil
+}
+
+// applyEnv overrides config from WEATHER_* variables and returns the names
+// of those that were set. Lists are separated by semicolons because
+// locations may contain commas.
+func applyEnv(config *Config, getenv func(string) string) ([]string, error) {
+	var used []string
+	var errs []error
+
+	lookup := func(name string) (string, bool) {
+		value := getenv(envPrefix + name)
+		if value == "" {
+			return "", false
+		}
+		used = append(used, envPrefix+name)
+		return value, true
+	}
+	str := func(name string, dst *string) {
+		if value, ok := lookup(name); ok {
+			*dst = value
+		}
+	}
+	num := func(name string, dst *int) {
+		if value, ok := lookup(name); ok {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %q is not a whole number", envPrefix, name, value))
+				return
+			}
+			*dst = n
+		}
+	}
+	boolean := func(name string, dst *bool) {
+		if value, ok := lookup(name); ok {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %q is not true or false", envPrefix, name, value))
+				return
+			}
+			*dst = b
+		}
+	}
+	list := func(name string, dst *[]string) {
+		if value, ok := lookup(name); ok {
+			*dst = nil
+			for _, item := range strings.Split(value, ";") {
+				if item = strings.TrimSpace(item); item != "" {
+					*dst = append(*dst, item)
+				}
+			}
+		}
 	}
 
-	err = json.Unmarshal(file, &config)
+	str("CREDENTIALS_FILE", &config.CredentialsFile)
+	str("CREDENTIALS_COMMAND", &config.CredentialsCommand)
+	str("PROVIDER", &config.Provider)
+	str("DEFAULT_CITY", &config.City)
+	str("UNITS", &config.Units)
+	str("DATABASE_PATH", &config.DatabasePath)
+	num("RETENTION_DAYS", &config.RetentionDays)
+	list("COLLECT_LOCATIONS", &config.CollectLocations)
+	str("COLLECT_INTERVAL", &config.CollectInterval)
+	num("CONCURRENCY", &config.Concurrency)
+	str("REQUEST_TIMEOUT", &config.RequestTimeout)
+	num("MAX_RETRIES", &config.MaxRetries)
+	str("LISTEN_ADDR", &config.ListenAddr)
+	str("CACHE_DIR", &config.CacheDir)
+	str("CACHE_TTL", &config.CacheTTL)
+	boolean("OFFLINE", &config.Offline)
+
+	return used, errors.Join(errs...)
+}
+
+// applyFlags overrides config from global command line flags and returns the
+// names of those that were given.
+func applyFlags(config *Config) []string {
+	var used []string
+	flag.Visit(func(f *flag.Flag) {
+		used = append(used, "-"+f.Name)
+	})
+
+	if cliFlags.provider != "" {
+		config.Provider = cliFlags.provider
+	}
+	if cliFlags.units != "" {
+		config.Units = cliFlags.units
+	}
+	if cliFlags.offline {
+		config.Offline = true
+	}
+	config.RecordDir = cliFlags.recordDir
+	config.ReplayDir = cliFlags.replayDir
+	return used
+}
+
+// validateConfig reports every invalid setting at once, naming each by its
+// config file key.
+func validateConfig(config Config) error {
+	var errs []error
+	invalid := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if config.Provider != "" && !validProvider(config.Provider) {
+		invalid("provider", "unknown provider %q; use one of %s", config.Provider, strings.Join(providerNames, ", "))
+	}
+	if _, err := parseUnits(config.Units); err != nil {
+		invalid("units", "%v", err)
+	}
+
+	positiveDuration := func(key, value string) {
+		if value == "" {
+			return
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			invalid(key, "%q is not a duration; use a value such as \"90s\", \"15m\" or \"2h\"", value)
+		} else if d <= 0 {
+			invalid(key, "must be positive, got %s", value)
+		}
+	}
+	positiveDuration("collect_interval", config.CollectInterval)
+	positiveDuration("request_timeout", config.RequestTimeout)
+	positiveDuration("cache_ttl", config.CacheTTL)
+
+	if config.Concurrency < 1 {
+		invalid("concurrency", "must be at least 1, got %d", config.Concurrency)
+	}
+	if config.MaxRetries < 0 {
+		invalid("max_retries", "must not be negative, got %d", config.MaxRetries)
+	}
+	if config.RetentionDays < 0 {
+		invalid("retention_days", "must not be negative, got %d (use 0 to keep readings forever)", config.RetentionDays)
+	}
+	if config.ListenAddr != "" {
+		if _, _, err := net.SplitHostPort(config.ListenAddr); err != nil {
+			invalid("listen_addr", "%q is not host:port, e.g. \"localhost:8080\" or \":8080\"", config.ListenAddr)
+		}
+	}
+
+	for provider, quota := range config.Quotas {
+		key := "quotas." + provider
+		if !validProvider(provider) && provider != aviationWeather {
+			invalid(key, "unknown provider %q", provider)
+		}
+		if quota.RequestsPerMinute < 0 || quota.Burst < 0 || quota.DailyLimit < 0 || quota.MonthlyLimit < 0 {
+			invalid(key, "limits must not be negative")
+		}
+		if quota.WarnPercent < 0 || quota.WarnPercent > 100 {
+			invalid(key+".warn_percent", "must be between 0 and 100, got %d", quota.WarnPercent)
+		}
+	}
+
+	validateAlertRules(config.Alerts, invalid)
+	validateNotifications(config.Notifications, config.Alerts, invalid)
+
+	seen := make(map[string]bool)
+	for i, saved := range config.Locations {
+		key := fmt.Sprintf("locations[%d]", i)
+		if err := validateAlias(saved.Alias); err != nil {
+			invalid(key, "%v", err)
+		}
+		if alias := strings.ToLower(saved.Alias); seen[alias] {
+			invalid(key, "alias %q is used more than once", saved.Alias)
+		} else {
+			seen[alias] = true
+		}
+		if saved.Lat < -90 || saved.Lat > 90 || saved.Lon < -180 || saved.Lon > 180 {
+			invalid(key, "coordinates %.4f,%.4f are out of range", saved.Lat, saved.Lon)
+		}
+		if saved.Provider != "" && !validProvider(saved.Provider) {
+			invalid(key+".provider", "unknown provider %q", saved.Provider)
+		}
+		if saved.Units != "" {
+			if _, err := parseUnits(saved.Units); err != nil {
+				invalid(key+".units", "%v", err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validProvider(name string) bool {
+	return slices.Contains(providerNames, strings.ToLower(name))
+}
+
+// shortDuration formats d without trailing zero units ("15m", not "15m0s").
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// readConfigFile reads only the project config file, for commands that
+// edit it.
+func readConfigFile() (Config, error) {
+	var config Config
+
+	found, err := readConfigLayer(configFileName, &config)
+	if err == nil && !found {
+		err = os.ErrNotExist
+	}
 	return config, err
 }
 
 func createDefaultConfig() error {
 	config := Config{
-		APIKey: "YOUR_API_KEY_HERE",
-		Units:  "metric",
-		City:   "London",
+		Provider: providerOpenMeteo,
+		Units:    "metric",
+		City:     "London",
 	}
+	return writeConfigFile(config)
+}
 
+// writeConfigFile saves config to the project config file.
+func writeConfigFile(config Config) error {
 	file, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return err
 	}
+	return os.WriteFile(configFileName, file, 0644)
+}
+
+// redactedConfig returns a copy of config that is safe to print.
+func redactedConfig(config Config) Config {
+	config.APIKey = redact(config.APIKey)
+	if len(config.APIKeys) > 0 {
+		keys := make(map[string]string, len(config.APIKeys))
+		for provider, key := range config.APIKeys {
+			keys[provider] = redact(key)
+		}
+		config.APIKeys = keys
+	}
+	if len(config.Notifications) > 0 {
+		sinks := make([]NotificationSink, len(config.Notifications))
+		for i, sink := range config.Notifications {
+			sinks[i] = redactedSink(sink)
+		}
+		config.Notifications = sinks
+	}
+	return config
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+// runConfig implements the "config" command.
+func runConfig(ctx context.Context, args []string) error {
+	flags := newCommandFlags("config")
+	effective := flags.Bool("effective", false, "print the merged configuration with secrets redacted")
+	flags.Parse(args)
+	if flags.Arg(0) != "show" {
+		return usageError("usage: config show [--effective]")
+	}
+	flags.Parse(flags.Args()[1:])
+
+	config, err := loadConfig()
+	if *effective {
+		data, jsonErr := json.MarshalIndent(redactedConfig(config), "", "  ")
+		if jsonErr != nil {
+			return jsonErr
+		}
+		fmt.Println(string(data))
+		if err != nil {
+			return configError(err)
+		}
+		return nil
+	}
 
-	return os.WriteFile("config.json", file, 0644)
-}
\ No newline at end of file
+	fmt.Println("\n⚙️  Configuration Sources (lowest to highest precedence)")
+	fmt.Println("=======================================================")
+	fmt.Println("defaults")
+	for _, path := range configFiles() {
+		status := "not found"
+		if slices.Contains(loadedSources.Files, path) {
+			status = "loaded"
+		}
+		fmt.Printf("%-50s %s\n", path, status)
+	}
+	if len(loadedSources.Env) > 0 {
+		fmt.Printf("environment: %s\n", strings.Join(loadedSources.Env, ", "))
+	}
+	if len(loadedSources.Flags) > 0 {
+		fmt.Printf("flags: %s\n", strings.Join(loadedSources.Flags, ", "))
+	}
+	fmt.Println("\nRun 'config show --effective' to see the merged result.")
+	if err != nil {
+		return configError(err)
+	}
+	return nil
+}
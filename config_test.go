@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConcurrencyMustBePositive(t *testing.T) {
+	for value, valid := range map[string]bool{"-1": false, "0": false, "1": true, "8": true} {
+		getenv := func(name string) string {
+			if name == envPrefix+"CONCURRENCY" {
+				return value
+			}
+			return ""
+		}
+		_, _, err := buildConfig(nil, getenv)
+		if valid && err != nil {
+			t.Errorf("concurrency %s: %v", value, err)
+		}
+		if !valid && (err == nil || !strings.Contains(err.Error(), "concurrency: must be at least 1")) {
+			t.Errorf("concurrency %s: err = %v, want it rejected", value, err)
+		}
+	}
+}
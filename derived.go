@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Providers report a feels-like temperature of their own choosing; the
+// quantities here are computed from temperature, humidity and wind with
+// published formulas, so stored readings and forecasts from any provider
+// can be compared.
+
+// DerivedMetrics are computed from one reading or forecast hour. Humidex is
+// dimensionless but reads like a temperature in °C; absolute humidity is in
+// grams of water vapour per cubic metre (Gm3). All but wind chill need the
+// humidity and are nil when the provider reported none.
+type DerivedMetrics struct {
+	DewPointC           *float64 `json:"dewpoint_c,omitempty"`
+	HeatIndexC          *float64 `json:"heat_index_c,omitempty"`
+	WindChillC          float64  `json:"wind_chill_c"`
+	Humidex             *float64 `json:"humidex,omitempty"`
+	WetBulbC            *float64 `json:"wetbulb_c,omitempty"`
+	AbsoluteHumidityGm3 *float64 `json:"absolute_humidity_gm3,omitempty"`
+}
+
+// Derived computes the derived metrics of a reading.
+func (w WeatherData) Derived() DerivedMetrics {
+	return deriveMetrics(w.TempC, float64(w.Humidity), w.WindKph)
+}
+
+// Derived computes the derived metrics of a forecast hour.
+func (h ForecastHour) Derived() DerivedMetrics {
+	return deriveMetrics(h.TempC, float64(h.Humidity), h.WindKph)
+}
+
+func deriveMetrics(tempC, humidity, windKph float64) DerivedMetrics {
+	metrics := DerivedMetrics{WindChillC: round1(windChillC(tempC, windKph))}
+	// A humidity of 0 means the provider had none. No air is that dry, so
+	// the metrics that need it are left out rather than guessed.
+	if humidity <= 0 {
+		return metrics
+	}
+	humidity = math.Min(humidity, 100)
+	dewPoint := dewPointC(tempC, humidity)
+	metrics.DewPointC = rounded(dewPoint)
+	metrics.HeatIndexC = rounded(heatIndexC(tempC, humidity))
+	metrics.Humidex = rounded(humidex(tempC, dewPoint))
+	metrics.WetBulbC = rounded(wetBulbC(tempC, humidity))
+	metrics.AbsoluteHumidityGm3 = rounded(absoluteHumidity(tempC, humidity))
+	return metrics
+}
+
+func round1(value float64) float64 {
+	return math.Round(value*10) / 10
+}
+
+// rounded returns value rounded to one decimal, for the optional metrics.
+func rounded(value float64) *float64 {
+	value = round1(value)
+	return &value
+}
+
+// format lists the metrics on one line, leaving out those that are nil.
+func (d DerivedMetrics) format(units UnitSystem) string {
+	var parts []string
+	if d.DewPointC != nil {
+		parts = append(parts, "Dew point "+units.FormatTemp(*d.DewPointC), "Heat index "+units.FormatTemp(*d.HeatIndexC))
+	}
+	parts = append(parts, "Wind chill "+units.FormatTemp(d.WindChillC))
+	if d.Humidex != nil {
+		parts = append(parts, fmt.Sprintf("Humidex %.0f", *d.Humidex), "Wet bulb "+units.FormatTemp(*d.WetBulbC),
+			fmt.Sprintf("%.1f g/m³", *d.AbsoluteHumidityGm3))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// fahrenheit converts for the heat index, whose regression is in °F.
+var fahrenheit = UnitSystem{TempUnit: unitFahrenheit}
+
+// dewPointC uses the Magnus formula with the Alduchov and Eskridge (1996)
+// coefficients, accurate to 0.35 °C between -40 and 50 °C.
+func dewPointC(tempC, humidity float64) float64 {
+	const a, b = 17.625, 243.04
+	gamma := math.Log(humidity/100) + a*tempC/(b+tempC)
+	return b * gamma / (a - gamma)
+}
+
+// heatIndexC follows the US National Weather Service: Steadman's simple
+// formula when the heat index is below 80 °F, otherwise the Rothfusz
+// regression with its low and high humidity adjustments.
+func heatIndexC(tempC, humidity float64) float64 {
+	t := fahrenheit.Temp(tempC)
+	rh := humidity
+
+	hi := 0.5 * (t + 61 + (t-68)*1.2 + rh*0.094)
+	if (hi+t)/2 < 80 {
+		return fahrenheit.Celsius(hi)
+	}
+
+	hi = -42.379 + 2.04901523*t + 10.14333127*rh - 0.22475541*t*rh - 0.00683783*t*t -
+		0.05481717*rh*rh + 0.00122874*t*t*rh + 0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+	switch {
+	case rh < 13 && t >= 80 && t <= 112:
+		hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+	case rh > 85 && t >= 80 && t <= 87:
+		hi += (rh - 85) / 10 * (87 - t) / 5
+	}
+	return fahrenheit.Celsius(hi)
+}
+
+// windChillC uses the 2001 NWS and Environment Canada formula, defined at
+// or below 10 °C with wind of at least 4.8 km/h; otherwise there is no wind
+// chill and the air temperature is returned.
+func windChillC(tempC, windKph float64) float64 {
+	if tempC > 10 || windKph < 4.8 {
+		return tempC
+	}
+	v := math.Pow(windKph, 0.16)
+	return 13.12 + 0.6215*tempC - 11.37*v + 0.3965*tempC*v
+}
+
+// humidex is Environment Canada's index from temperature and dew point.
+func humidex(tempC, dewPointC float64) float64 {
+	vapourPressure := 6.11 * math.Exp(5417.7530*(1/273.16-1/(273.15+dewPointC)))
+	return tempC + 0.5555*(vapourPressure-10)
+}
+
+// wetBulbC uses Stull's (2011) empirical formula, valid for humidity from
+// 5 to 99% and temperatures from -20 to 50 °C at sea level pressure.
+func wetBulbC(tempC, humidity float64) float64 {
+	return tempC*math.Atan(0.151977*math.Sqrt(humidity+8.313659)) +
+		math.Atan(tempC+humidity) - math.Atan(humidity-1.676331) +
+		0.00391838*math.Pow(humidity, 1.5)*math.Atan(0.023101*humidity) - 4.686035
+}
+
+// absoluteHumidity returns grams of water vapour per cubic metre, from the
+// saturation vapour pressure (Bolton 1980) and the ideal gas law.
+func absoluteHumidity(tempC, humidity float64) float64 {
+	saturation := 6.112 * math.Exp(17.67*tempC/(tempC+243.5))
+	return saturation * humidity * 2.1674 / (273.15 + tempC)
+}
+
+// DerivedSummary describes the derived metrics over a set of readings. The
+// metrics that need the humidity only count readings that have it, and are
+// nil when none does.
+type DerivedSummary struct {
+	AverageDewPointC           *float64 `json:"average_dewpoint_c,omitempty"`
+	MaxHeatIndexC              *float64 `json:"max_heat_index_c,omitempty"`
+	MinWindChillC              float64  `json:"min_wind_chill_c"`
+	MaxHumidex                 *float64 `json:"max_humidex,omitempty"`
+	MaxWetBulbC                *float64 `json:"max_wetbulb_c,omitempty"`
+	AverageAbsoluteHumidityGm3 *float64 `json:"average_absolute_humidity_gm3,omitempty"`
+}
+
+func summarizeDerived(data []WeatherData) DerivedSummary {
+	var summary DerivedSummary
+	var dewPoints, absoluteHumidities float64
+	humid := 0
+	for i, reading := range data {
+		derived := reading.Derived()
+		if i == 0 || derived.WindChillC < summary.MinWindChillC {
+			summary.MinWindChillC = derived.WindChillC
+		}
+		if derived.DewPointC == nil {
+			continue
+		}
+		humid++
+		dewPoints += *derived.DewPointC
+		absoluteHumidities += *derived.AbsoluteHumidityGm3
+		summary.MaxHeatIndexC = higher(summary.MaxHeatIndexC, derived.HeatIndexC)
+		summary.MaxHumidex = higher(summary.MaxHumidex, derived.Humidex)
+		summary.MaxWetBulbC = higher(summary.MaxWetBulbC, derived.WetBulbC)
+	}
+	if humid > 0 {
+		summary.AverageDewPointC = rounded(dewPoints / float64(humid))
+		summary.AverageAbsoluteHumidityGm3 = rounded(absoluteHumidities / float64(humid))
+	}
+	return summary
+}
+
+// higher returns the larger of two optional values.
+func higher(a, b *float64) *float64 {
+	if a == nil || (b != nil && *b > *a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// Published tables round to whole degrees, so values within half a degree
+// of them match.
+const tableTolerance = 0.5
+
+// NWS heat index chart, in °F and % relative humidity.
+func TestHeatIndexNWSTable(t *testing.T) {
+	tests := []struct{ tempF, humidity, want float64 }{
+		{80, 40, 80},
+		{80, 80, 84},
+		{84, 70, 90},
+		{86, 90, 105},
+		{90, 50, 95},
+		{96, 60, 116},
+		{100, 40, 109},
+		{104, 40, 119},
+	}
+	for _, tt := range tests {
+		got := fahrenheit.Temp(heatIndexC(fahrenheit.Celsius(tt.tempF), tt.humidity))
+		if math.Abs(got-tt.want) > tableTolerance {
+			t.Errorf("heat index at %g°F and %g%% = %.1f°F, want %g°F", tt.tempF, tt.humidity, got, tt.want)
+		}
+	}
+}
+
+// NWS wind chill chart, in °F and mph.
+func TestWindChillNWSTable(t *testing.T) {
+	imperial := UnitSystem{TempUnit: unitFahrenheit, SpeedUnit: unitMph}
+	tests := []struct{ tempF, windMph, want float64 }{
+		{35, 25, 23},
+		{30, 10, 21},
+		{20, 30, 1},
+		{0, 15, -19},
+		{-10, 20, -35},
+		{-20, 60, -62},
+	}
+	for _, tt := range tests {
+		windKph := tt.windMph / imperial.Speed(1)
+		got := imperial.Temp(windChillC(imperial.Celsius(tt.tempF), windKph))
+		if math.Abs(got-tt.want) > tableTolerance {
+			t.Errorf("wind chill at %g°F and %g mph = %.1f°F, want %g°F", tt.tempF, tt.windMph, got, tt.want)
+		}
+	}
+
+	// Above 10 °C or below 4.8 km/h there is no wind chill.
+	if got := windChillC(15, 30); got != 15 {
+		t.Errorf("wind chill at 15 °C = %g, want the air temperature", got)
+	}
+	if got := windChillC(-5, 3); got != -5 {
+		t.Errorf("wind chill in 3 km/h of wind = %g, want the air temperature", got)
+	}
+}
+
+// Environment Canada humidex table, by air temperature and dew point in °C.
+func TestHumidexEnvironmentCanadaTable(t *testing.T) {
+	tests := []struct{ tempC, dewPointC, want float64 }{
+		{25, 20, 33},
+		{30, 15, 34},
+		{30, 25, 42},
+		{35, 20, 43},
+	}
+	for _, tt := range tests {
+		if got := humidex(tt.tempC, tt.dewPointC); math.Abs(got-tt.want) > tableTolerance {
+			t.Errorf("humidex at %g °C with dew point %g °C = %.1f, want %g", tt.tempC, tt.dewPointC, got, tt.want)
+		}
+	}
+}
+
+// Stull (2011) gives 13.7 °C for 20 °C and 50%, and reports his formula
+// within -1 to +0.65 °C of psychrometric wet-bulb temperatures at sea
+// level, shown here to 0.1 °C.
+func TestWetBulbStull(t *testing.T) {
+	if got := wetBulbC(20, 50); math.Abs(got-13.7) > 0.05 {
+		t.Errorf("wet bulb at 20 °C and 50%% = %.2f °C, want 13.7 °C", got)
+	}
+
+	tests := []struct{ tempC, humidity, want float64 }{
+		{10, 90, 9.2},
+		{25, 80, 22.4},
+		{30, 50, 22.1},
+		{35, 40, 24.1},
+		{40, 20, 22.3},
+	}
+	for _, tt := range tests {
+		if got := wetBulbC(tt.tempC, tt.humidity); got-tt.want < -1 || got-tt.want > 0.65 {
+			t.Errorf("wet bulb at %g °C and %g%% = %.2f °C, want %g °C", tt.tempC, tt.humidity, got, tt.want)
+		}
+	}
+}
+
+func TestDeriveMetricsWithoutHumidity(t *testing.T) {
+	for _, humidity := range []float64{0, -5} {
+		derived := deriveMetrics(5, humidity, 20)
+		if derived.DewPointC != nil || derived.HeatIndexC != nil || derived.Humidex != nil ||
+			derived.WetBulbC != nil || derived.AbsoluteHumidityGm3 != nil {
+			t.Errorf("humidity %g: derived = %+v, want only wind chill", humidity, derived)
+		}
+		if derived.WindChillC != round1(windChillC(5, 20)) {
+			t.Errorf("humidity %g: wind chill = %g", humidity, derived.WindChillC)
+		}
+	}
+	if got := deriveMetrics(5, 0, 20).format(UnitSystem{}); got != "Wind chill 1.1°C" {
+		t.Errorf("format = %q", got)
+	}
+}
+
+func TestSummarizeDerivedSkipsMissingHumidity(t *testing.T) {
+	data := []WeatherData{
+		{TempC: 30, Humidity: 50},
+		{TempC: 35, Humidity: 0},
+		{TempC: 20, Humidity: 50},
+	}
+	summary := summarizeDerived(data)
+	want := *WeatherData{TempC: 30, Humidity: 50}.Derived().HeatIndexC
+	if summary.MaxHeatIndexC == nil || *summary.MaxHeatIndexC != want {
+		t.Errorf("max heat index = %v, want %g from the readings with humidity", summary.MaxHeatIndexC, want)
+	}
+	if summary.MinWindChillC != 20 {
+		t.Errorf("min wind chill = %g, want 20", summary.MinWindChillC)
+	}
+
+	summary = summarizeDerived(data[1:2])
+	if summary.AverageDewPointC != nil || summary.MaxHumidex != nil {
+		t.Errorf("summary without humidity = %+v", summary)
+	}
+}
+
+func TestAlertsSkipMissingHumidity(t *testing.T) {
+	now := time.Now()
+	forecast := &Forecast{
+		Current: WeatherData{TempC: 35, Humidity: 0},
+		Days: []ForecastDay{{Hours: []ForecastHour{
+			{Time: now.Add(time.Hour), TempC: 28, Humidity: 60},
+			{Time: now.Add(2 * time.Hour), TempC: 40, Humidity: 0},
+		}}},
+	}
+	rule := AlertRule{Metric: "heat_index", Operator: alertAbove, Threshold: 30, Within: "3h"}
+	value, at, ok := rule.worst(forecast, now)
+	want := *ForecastHour{TempC: 28, Humidity: 60}.Derived().HeatIndexC
+	if !ok || value != want || !at.Equal(now.Add(time.Hour)) {
+		t.Errorf("worst = %g at %v (%v), want %g from the hour with humidity", value, at, ok, want)
+	}
+
+	rule.Within = ""
+	if _, _, ok := rule.worst(forecast, now); ok {
+		t.Error("worst found a heat index without humidity")
+	}
+}
+
+func TestChartHeader(t *testing.T) {
+	imperial, _ := parseUnits("imperial")
+	tests := map[string]string{
+		"temp":              "Temp (°F)",
+		"heat_index":        "Heat index (°F)",
+		"wind":              "Wind (mph)",
+		"humidity":          "Humidity (%)",
+		"humidex":           "Humidex",
+		"absolute_humidity": "Absolute humidity (g/m³)",
+	}
+	for name, want := range tests {
+		if got := alertMetrics[name].header(name, imperial); got != want {
+			t.Errorf("header(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConcurrency    = 4
+	defaultRequestTimeout = 15 * time.Second
+)
+
+// fetchResult is the outcome of fetching one location.
+type fetchResult struct {
+	Location string
+	Data     *WeatherData
+	Err      error
+}
+
+// fetchMany fetches current conditions for every location using at most
+// concurrency requests at a time. Results are returned in the same order as
+// locations; a failed location carries its error instead of data. Each
+// location is fetched from its provider in providers, and resolved first
+// when resolver is not nil.
+func fetchMany(ctx context.Context, providers *providerSet, resolver *LocationResolver, locations []string, concurrency int, timeout time.Duration) []fetchResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]fetchResult, len(locations))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(concurrency, len(locations)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				data, err := fetchWithTimeout(ctx, providers, resolver, locations[i], timeout)
+				results[i] = fetchResult{Location: locations[i], Data: data, Err: err}
+			}
+		}()
+	}
+
+	for i := range locations {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			results[i] = fetchResult{Location: locations[i], Err: ctx.Err()}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// fetchWithTimeout gives up on a single location once timeout has passed.
+func fetchWithTimeout(ctx context.Context, providers *providerSet, resolver *LocationResolver, location string, timeout time.Duration) (*WeatherData, error) {
+	provider, err := providers.provider(location)
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if resolver == nil {
+		return provider.CurrentWeather(ctx, location)
+	}
+
+	loc, err := resolver.Resolve(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	data, err := provider.CurrentWeather(ctx, loc.Query())
+	if err != nil {
+		return nil, err
+	}
+	data.Location = loc.canonical(data.Location)
+	addAirQuality(ctx, provider, loc.Query(), data)
+	return data, nil
+}
+
+// readLocationsFile reads one location per line, skipping blank lines and
+// lines starting with #.
+func readLocationsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var locations []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		locations = append(locations, line)
+	}
+	return locations, scanner.Err()
+}
+
+// fetchSettings returns the configured concurrency and per-request timeout.
+func fetchSettings(config Config) (int, time.Duration, error) {
+	concurrency := config.Concurrency
+	if concurrency == 0 {
+		concurrency = defaultConcurrency
+	}
+
+	timeout := defaultRequestTimeout
+	if config.RequestTimeout != "" {
+		parsed, err := time.ParseDuration(config.RequestTimeout)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid request_timeout %q: %v", config.RequestTimeout, err)
+		}
+		timeout = parsed
+	}
+	return concurrency, timeout, nil
+}
+
+// runCompare implements the "compare" command: fetch several locations at once
+// and chart them side by side.
+func runCompare(ctx context.Context, args []string) error {
+	config, _ := loadConfig()
+	concurrency, timeout, err := fetchSettings(config)
+	if err != nil {
+		return err
+	}
+
+	flags := newCommandFlags("compare")
+	flags.IntVar(&concurrency, "concurrency", concurrency, "maximum simultaneous requests")
+	flags.DurationVar(&timeout, "timeout", timeout, "timeout for each location")
+	file := flags.String("file", "", "read locations from a file, one per line")
+	flags.Parse(args)
+
+	locations := flags.Args()
+	if *file != "" {
+		fromFile, err := readLocationsFile(*file)
+		if err != nil {
+			return fmt.Errorf("could not read locations: %v", err)
+		}
+		locations = append(locations, fromFile...)
+	}
+	if len(locations) == 0 {
+		return usageError("no locations to compare; pass them as arguments or with -file")
+	}
+
+	provider, err := newProvider(config)
+	if err != nil {
+		return err
+	}
+	providers := newProviderSet(config, provider)
+	resolver, err := newLocationResolver(config, false)
+	if err != nil {
+		return err
+	}
+
+	results := fetchMany(ctx, providers, resolver, locations, concurrency, timeout)
+
+	var data []WeatherData
+	var failed []fetchResult
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result)
+			continue
+		}
+		data = append(data, *result.Data)
+	}
+
+	if len(data) > 0 {
+		generateVisualization(data, compareUnits(config, providers, locations))
+	}
+
+	if len(failed) > 0 {
+		fmt.Printf("\n⚠️  %d of %d locations failed:\n", len(failed), len(results))
+		for _, result := range failed {
+			fmt.Printf("  %s: %v\n", result.Location, result.Err)
+		}
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("all %d locations failed", len(results))
+	}
+	return nil
+}
+
+// compareUnits returns the units a comparison is charted in: those of the
+// saved locations when they all agree, so comparing "home" alone matches
+// "current home", and the configured units otherwise.
+func compareUnits(config Config, providers *providerSet, locations []string) UnitSystem {
+	units := providers.units(locations[0])
+	for _, location := range locations[1:] {
+		if providers.units(location) != units {
+			units, _ = parseUnits(config.Units) // validated by loadConfig
+			break
+		}
+	}
+	return units
+}
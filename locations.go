@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// SavedLocation is a named place from the config, such as "home" or
+// "office". Provider and Units override the global settings when the
+// location is fetched on its own.
+type SavedLocation struct {
+	Alias    string  `json:"alias"`
+	Name     string  `json:"name"`
+	Region   string  `json:"region,omitempty"`
+	Country  string  `json:"country,omitempty"`
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+	Timezone string  `json:"timezone,omitempty"`
+	Provider string  `json:"provider,omitempty"`
+	Units    string  `json:"units,omitempty"`
+}
+
+func (s SavedLocation) location() Location {
+	return Location{
+		Name:     s.Name,
+		Region:   s.Region,
+		Country:  s.Country,
+		Lat:      s.Lat,
+		Lon:      s.Lon,
+		Timezone: s.Timezone,
+	}
+}
+
+// savedLocation looks up an alias, ignoring case.
+func savedLocation(saved []SavedLocation, alias string) (SavedLocation, bool) {
+	alias = strings.TrimSpace(alias)
+	for _, s := range saved {
+		if strings.EqualFold(s.Alias, alias) {
+			return s, true
+		}
+	}
+	return SavedLocation{}, false
+}
+
+// withSavedLocation applies a saved location's provider and units to config;
+// those given on the command line still win.
+func withSavedLocation(config Config, location string) Config {
+	if saved, ok := savedLocation(config.Locations, location); ok {
+		if saved.Provider != "" && cliFlags.provider == "" {
+			config.Provider = saved.Provider
+		}
+		if saved.Units != "" && cliFlags.units == "" {
+			config.Units = saved.Units
+		}
+	}
+	return config
+}
+
+// providerSet hands out the provider and units each location of a
+// multi-location command is fetched and shown with: a saved location's own,
+// or the configured ones. Locations that use the same provider share it.
+type providerSet struct {
+	config Config
+
+	mu        sync.Mutex
+	providers map[string]WeatherProvider // by provider setting
+}
+
+// newProviderSet returns a set where locations without a provider of their
+// own use fallback, the provider built from config.
+func newProviderSet(config Config, fallback WeatherProvider) *providerSet {
+	return &providerSet{
+		config:    config,
+		providers: map[string]WeatherProvider{config.Provider: fallback},
+	}
+}
+
+// provider returns the provider for location, building it on first use.
+func (p *providerSet) provider(location string) (WeatherProvider, error) {
+	config := withSavedLocation(p.config, location)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if provider, ok := p.providers[config.Provider]; ok {
+		return provider, nil
+	}
+	provider, err := newProvider(config)
+	if err != nil {
+		return nil, err
+	}
+	p.providers[config.Provider] = provider
+	return provider, nil
+}
+
+// units returns the units location is shown in.
+func (p *providerSet) units(location string) UnitSystem {
+	units, _ := parseUnits(withSavedLocation(p.config, location).Units) // validated by loadConfig
+	return units
+}
+
+func validateAlias(alias string) error {
+	switch {
+	case alias == "":
+		return fmt.Errorf("alias must not be empty")
+	case strings.Contains(alias, ","):
+		return fmt.Errorf("alias %q must not contain a comma", alias)
+	case icaoPattern.MatchString(alias):
+		return fmt.Errorf("alias %q would shadow an airport code", alias)
+	}
+	return nil
+}
+
+// runLocations implements the "locations" command: add, list and remove
+// saved locations.
+func runLocations(ctx context.Context, args []string) error {
+	flags := newCommandFlags("locations")
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) == 0 {
+		return usageError("usage: locations add|list|remove")
+	}
+
+	// Edit the file itself so command line overrides are not written back
+	config, err := readConfigFile()
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not read config.json: %v", err)
+	}
+
+	switch args[0] {
+	case "add":
+		return addLocation(ctx, config, args[1:])
+	case "list":
+		// List what commands will use, from whichever file defines it
+		effective, _ := loadConfig()
+		listLocations(effective.Locations, locationSources())
+		return nil
+	case "remove":
+		if len(args) != 2 {
+			return usageError("usage: locations remove <alias>")
+		}
+		return removeLocation(config, args[1])
+	default:
+		return usageError("unknown locations command %q; use add, list or remove", args[0])
+	}
+}
+
+func addLocation(ctx context.Context, config Config, args []string) error {
+	flags := flag.NewFlagSet("locations add", flag.ExitOnError)
+	provider := flags.String("provider", "", "provider to use for this location")
+	units := flags.String("units", "", "units to use for this location")
+	flags.Parse(args)
+
+	if flags.NArg() < 2 {
+		return usageError("usage: locations add [-provider name] [-units units] <alias> <place>")
+	}
+	alias := flags.Arg(0)
+	place := strings.Join(flags.Args()[1:], " ")
+	if err := validateAlias(alias); err != nil {
+		return err
+	}
+	if *provider != "" && !slices.Contains(providerNames, strings.ToLower(*provider)) {
+		return fmt.Errorf("unknown weather provider %q", *provider)
+	}
+	if _, err := parseUnits(*units); err != nil {
+		return err
+	}
+	if _, exists := savedLocation(config.Locations, alias); exists {
+		return fmt.Errorf("location %q already exists; remove it first", alias)
+	}
+
+	// Resolve against the effective config so offline and replay still apply,
+	// but without the existing aliases: a new alias names a real place.
+	effective, _ := loadConfig()
+	effective.Locations = nil
+	resolver, err := newLocationResolver(effective, interactive())
+	if err != nil {
+		return err
+	}
+	loc, err := resolver.Resolve(ctx, place)
+	if err != nil {
+		return err
+	}
+
+	// A bare coordinate pair is better shown under its alias
+	if _, _, ok := parseCoordinates(loc.Name); ok {
+		loc.Name = alias
+	}
+
+	config.Locations = append(config.Locations, SavedLocation{
+		Alias:    alias,
+		Name:     loc.Name,
+		Region:   loc.Region,
+		Country:  loc.Country,
+		Lat:      loc.Lat,
+		Lon:      loc.Lon,
+		Timezone: loc.Timezone,
+		Provider: *provider,
+		Units:    *units,
+	})
+	if err := writeConfigFile(config); err != nil {
+		return err
+	}
+
+	fmt.Printf("Saved %s as %s (%.4f, %.4f)\n", alias, loc.DisplayName(), loc.Lat, loc.Lon)
+	return nil
+}
+
+// locationSources returns the config file each saved location is defined
+// in, by alias. Later files replace the whole list, so the last file that
+// has locations wins.
+func locationSources() map[string]string {
+	sources := make(map[string]string)
+	for _, path := range configFiles() {
+		var layer Config
+		if found, err := readConfigLayer(path, &layer); err != nil || !found || layer.Locations == nil {
+			continue
+		}
+		clear(sources)
+		for _, s := range layer.Locations {
+			sources[strings.ToLower(s.Alias)] = path
+		}
+	}
+	return sources
+}
+
+func listLocations(saved []SavedLocation, sources map[string]string) {
+	if len(saved) == 0 {
+		fmt.Println("No saved locations. Add one with: locations add <alias> <place>")
+		return
+	}
+
+	fmt.Println("\n📍 Saved Locations")
+	fmt.Println("==================")
+	for _, s := range saved {
+		fmt.Printf("%-15s %s (%.4f, %.4f)", s.Alias, s.location().DisplayName(), s.Lat, s.Lon)
+		if s.Provider != "" {
+			fmt.Printf(" provider=%s", s.Provider)
+		}
+		if s.Units != "" {
+			fmt.Printf(" units=%s", s.Units)
+		}
+		if source := sources[strings.ToLower(s.Alias)]; source != "" && source != configFileName {
+			fmt.Printf(" from %s", source)
+		}
+		fmt.Println()
+	}
+}
+
+func removeLocation(config Config, alias string) error {
+	for i, s := range config.Locations {
+		if strings.EqualFold(s.Alias, alias) {
+			config.Locations = append(config.Locations[:i], config.Locations[i+1:]...)
+			if err := writeConfigFile(config); err != nil {
+				return err
+			}
+			fmt.Printf("Removed %s\n", s.Alias)
+			return nil
+		}
+	}
+	if source := locationSources()[strings.ToLower(alias)]; source != "" {
+		return fmt.Errorf("%q is saved in %s; edit that file to remove it", alias, source)
+	}
+	return fmt.Errorf("no saved location %q", alias)
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestProviderSetUsesSavedSettings(t *testing.T) {
+	office := SavedLocation{Alias: "office", Name: "Austin", Lat: 30.27, Lon: -97.74,
+		Provider: providerOpenMeteo, Units: "imperial"}
+	config := Config{
+		Provider:  providerWeatherAPI,
+		Units:     "metric",
+		Locations: []SavedLocation{yard, office},
+		ReplayDir: "testdata/replay",
+	}
+	fallback := &fakeProvider{}
+	providers := newProviderSet(config, fallback)
+
+	for _, location := range []string{"yard", "Paris"} {
+		if provider, err := providers.provider(location); err != nil || provider != fallback {
+			t.Errorf("%s: provider = %v, %v; want the configured one", location, provider, err)
+		}
+		if units := providers.units(location); units.TempUnit != unitCelsius {
+			t.Errorf("%s: units = %+v, want metric", location, units)
+		}
+	}
+
+	provider, err := providers.provider("Office")
+	if err != nil || provider.Name() != providerOpenMeteo {
+		t.Fatalf("office: provider = %v, %v; want %s", provider, err, providerOpenMeteo)
+	}
+	if again, _ := providers.provider("office"); again != provider {
+		t.Error("office: provider built twice")
+	}
+	if units := providers.units("office"); units.TempUnit != unitFahrenheit {
+		t.Errorf("office: units = %+v, want imperial", units)
+	}
+
+	if units := compareUnits(config, providers, []string{"office"}); units.TempUnit != unitFahrenheit {
+		t.Errorf("comparing office alone: units = %+v, want imperial", units)
+	}
+	if units := compareUnits(config, providers, []string{"office", "yard"}); units.TempUnit != unitCelsius {
+		t.Errorf("comparing office and yard: units = %+v, want the configured metric", units)
+	}
+}
@@ -1,122 +1,345 @@
 package main
 
 import (
-	"encoding/json"
+	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
-	"log"
-	"net/http"
 	"os"
-	"strconv"
-	"time"
+	"os/signal"
+	"strings"
+	"syscall"
 )
 
-type WeatherData struct {
-	Location struct {
-		Name    string `json:"name"`
-		Country string `json:"country"`
-	} `json:"location"`
-	Current struct {
-		TempC     float64 `json:"temp_c"`
-		Condition struct {
-			Text string `json:"text"`
-		} `json:"condition"`
-		Humidity   int     `json:"humidity"`
-		WindKph    float64 `json:"wind_kph"`
-		FeelsLikeC float64 `json:"feelslike_c"`
-	} `json:"current"`
-	Forecast struct {
-		Forecastday []struct {
-			Date string `json:"date"`
-			Day  struct {
-				MaxTempC float64 `json:"maxtemp_c"`
-				MinTempC float64 `json:"mintemp_c"`
-				AvgTempC float64 `json:"avgtemp_c"`
-			} `json:"day"`
-		} `json:"forecastday"`
-	} `json:"forecast"`
-}
+const (
+	chartModeDaily  = "daily"
+	chartModeHourly = "hourly"
+)
+
+// Exit codes, so scripts can tell failures apart.
+const (
+	exitOK       = 0
+	exitFailure  = 1
+	exitUsage    = 2
+	exitConfig   = 3
+	exitNetwork  = 4
+	exitAuth     = 5
+	exitNotFound = 6
+)
 
 type WeatherAnalyzer struct {
-	Data []WeatherData
+	Data      []Forecast
+	ChartMode string
+	Units     UnitSystem
+}
+
+// command is a subcommand of the tool.
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+// commands returns every subcommand in the order they are listed in help.
+func commands() []command {
+	return []command{
+		{"current", "[flags] [location]", "Show current conditions", runCurrent},
+		{"forecast", "[flags] [location]", "Show the forecast with temperature trends and charts", runForecast},
+		{"history", "[flags] [location]", "Show the provider's recorded weather for a past day", runHistory},
+		{"analyze", "[flags] [location]", "Analyze readings stored by earlier runs", runAnalyze},
+		{"collect", "[flags] [location...]", "Poll locations on a schedule and store every reading", runCollect},
+		{"compare", "[flags] location...", "Fetch several locations at once and chart them side by side", runCompare},
+		{"serve", "[-addr host:port]", "Serve current, forecast, history and analysis over an HTTP API", runServe},
+		{"alerts", "[-output format] check|status|test|log", "Check alert rules, show firing alerts or test notifications", runAlerts},
+		{"locations", "add|list|remove ...", "Manage saved locations", runLocations},
+		{"config", "show [--effective]", "Show where the configuration comes from", runConfig},
+		{"quota", "", "Show API usage against each provider's limits", runQuota},
+		{"import", "file", "Import readings from a legacy " + dataFile + " file", runImport},
+		{"help", "[command]", "Show help for a command", runHelp},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// newCommandFlags returns the flag set for a subcommand, with usage text
+// built from its entry in commands.
+func newCommandFlags(name string) *flag.FlagSet {
+	cmd, _ := findCommand(name)
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		out := flags.Output()
+		fmt.Fprintf(out, "Usage: weather-analyzer %s %s\n\n%s.\n", cmd.name, cmd.args, cmd.summary)
+		hasFlags := false
+		flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintln(out, "\nFlags:")
+			flags.PrintDefaults()
+		}
+	}
+	return flags
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "Usage: weather-analyzer [global flags] <command> [flags] [arguments]")
+	fmt.Fprintln(out, "\nCommands:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(out, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(out, "\nGlobal flags:")
+	flag.PrintDefaults()
+	fmt.Fprintln(out, "\nExit codes:")
+	fmt.Fprintln(out, "  0 success, 1 other error, 2 usage, 3 configuration, 4 network,")
+	fmt.Fprintln(out, "  5 authentication, 6 location not found")
+	fmt.Fprintln(out, "\nRun 'weather-analyzer help <command>' for a command's flags.")
 }
 
 func main() {
-	fmt.Println("🌤️  Weather Data Analyzer")
-	fmt.Println("==========================")
+	flag.Usage = usage
+	flag.StringVar(&cliFlags.provider, "provider", "", "weather provider: weatherapi, openweathermap or open-meteo")
+	flag.StringVar(&cliFlags.units, "units", "", "unit system: metric, imperial, si or uk, with overrides such as metric,wind=mph")
+	flag.BoolVar(&cliFlags.offline, "offline", false, "serve responses only from the local cache")
+	flag.BoolVar(&cliFlags.nonInteractive, "non-interactive", false, "never prompt; also implied when stdin is not a terminal")
+	flag.StringVar(&cliFlags.recordDir, "record", "", "save raw provider responses as fixtures in `dir`")
+	flag.StringVar(&cliFlags.replayDir, "replay", "", "serve provider responses from fixtures in `dir` instead of the network")
+	flag.Parse()
 
-	// Get location from user or use default
-	location := getLocationInput()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(exitUsage)
+	}
+	cmd, ok := findCommand(flag.Arg(0))
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown command %q\n", flag.Arg(0))
+		fmt.Fprintf(os.Stderr, "Run 'weather-analyzer help' for the list of commands, or 'weather-analyzer forecast %s' for a location.\n", flag.Arg(0))
+		os.Exit(exitUsage)
+	}
 
-	// Fetch weather data
-	weatherData, err := fetchWeatherData(location)
+	// "config show" and "help" work even with a broken configuration
+	if _, err := loadConfig(); err != nil && cmd.name != "config" && cmd.name != "help" {
+		fail(configError(err))
+	}
+
+	// Ctrl-C and SIGTERM cancel in-flight requests
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := cmd.run(ctx, flag.Args()[1:])
+	stop()
+	closeQuotaTrackers()
 	if err != nil {
-		log.Fatalf("Error fetching weather data: %v", err)
+		fail(err)
 	}
+}
 
-	// Analyze and display results
-	analyzer := &WeatherAnalyzer{Data: []WeatherData{weatherData}}
-	analyzer.DisplayCurrentWeather()
-	analyzer.AnalyzeTemperatureTrends()
-	analyzer.VisualizeTemperatureTrends()
+// fail prints err and exits with the code for its kind.
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(exitCode(err))
 }
 
-func getLocationInput() string {
-	if len(os.Args) > 1 {
-		return os.Args[1]
+func runHelp(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		flag.CommandLine.SetOutput(os.Stdout)
+		usage()
+		return nil
 	}
-	
-	fmt.Print("Enter location (or press Enter for London): ")
-	var location string
-	fmt.Scanln(&location)
-	
+	cmd, ok := findCommand(args[0])
+	if !ok || cmd.name == "help" {
+		return usageError("unknown command %q", args[0])
+	}
+	// Every command parses its flags first, so -h prints them and exits
+	return cmd.run(ctx, []string{"-h"})
+}
+
+// interactive reports whether the user may be prompted.
+func interactive() bool {
+	return !cliFlags.nonInteractive && stdinIsTerminal()
+}
+
+// locationArg returns the location named by args, which may be split over
+// several words. Without one the user is asked, or in non-interactive mode
+// the default location is used.
+func locationArg(args []string) string {
+	if len(args) > 0 {
+		return strings.Join(args, " ")
+	}
+
+	// The default may be a saved location alias
+	config, _ := loadConfig()
+	defaultLocation := config.City
+	if !interactive() {
+		return defaultLocation
+	}
+
+	// Prompts go to stderr so they never mix with structured output
+	fmt.Fprintf(os.Stderr, "Enter location (or press Enter for %s): ", defaultLocation)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	location := strings.TrimSpace(line)
+
 	if location == "" {
-		return "London"
+		return defaultLocation
 	}
 	return location
 }
 
-func fetchWeatherData(location string) (WeatherData, error) {
-	apiKey := getAPIKey()
-	url := fmt.Sprintf("http://api.weatherapi.com/v1/forecast.json?key=%s&q=%s&days=7&aqi=no&alerts=no", apiKey, location)
+func printBanner() {
+	fmt.Println("🌤️  Weather Data Analyzer")
+	fmt.Println("==========================")
+}
 
-	resp, err := http.Get(url)
+// resolveLocation turns the user's location into a canonical one,
+// prompting among ambiguous matches when interactive.
+func resolveLocation(ctx context.Context, config Config, location string) (Location, error) {
+	resolver, err := newLocationResolver(config, interactive())
 	if err != nil {
-		return WeatherData{}, fmt.Errorf("failed to fetch data: %v", err)
+		return Location{}, err
 	}
-	defer resp.Body.Close()
+	return resolver.Resolve(ctx, location)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return WeatherData{}, fmt.Errorf("API returned status: %s", resp.Status)
+func fetchWeatherData(ctx context.Context, config Config, location string, days int) (*Forecast, error) {
+	provider, err := newProvider(config)
+	if err != nil {
+		return nil, err
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	_, timeout, err := fetchSettings(config)
 	if err != nil {
-		return WeatherData{}, fmt.Errorf("failed to read response: %v", err)
+		return nil, err
 	}
 
-	var weatherData WeatherData
-	err = json.Unmarshal(body, &weatherData)
+	// Resolve before starting the timeout; picking among matches may wait on
+	// the user
+	loc, err := resolveLocation(ctx, config, location)
 	if err != nil {
-		return WeatherData{}, fmt.Errorf("failed to parse JSON: %v", err)
+		return nil, err
 	}
 
-	return weatherData, nil
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	forecast, err := provider.Forecast(ctx, loc.Query(), days)
+	if err != nil {
+		return nil, err
+	}
+	forecast.Location = loc.canonical(forecast.Location)
+	forecast.Current.Location = forecast.Location
+	addAirQuality(ctx, provider, loc.Query(), &forecast.Current)
+	return forecast, nil
 }
 
-func getAPIKey() string {
-	// First try environment variable
-	if apiKey := os.Getenv("WEATHER_API_KEY"); apiKey != "" {
-		return apiKey
+// fetchCurrentWeather returns the current conditions, and the official
+// warnings in force, as a forecast without days.
+func fetchCurrentWeather(ctx context.Context, config Config, location string) (*Forecast, error) {
+	provider, err := newProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	_, timeout, err := fetchSettings(config)
+	if err != nil {
+		return nil, err
 	}
 
-	// Then try config file
-	config, err := loadConfig()
-	if err == nil && config.APIKey != "" {
-		return config.APIKey
+	loc, err := resolveLocation(ctx, config, location)
+	if err != nil {
+		return nil, err
 	}
 
-	// Fallback to demo key (limited usage)
-	fmt.Println("⚠️  Using demo API key (limited requests)")
-	return "demo_key_will_not_work_use_real_key"
-}
\ No newline at end of file
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	weather, err := provider.CurrentWeather(ctx, loc.Query())
+	if err != nil {
+		return nil, err
+	}
+	weather.Location = loc.canonical(weather.Location)
+	addAirQuality(ctx, provider, loc.Query(), weather)
+	return &Forecast{
+		Location: weather.Location,
+		Current:  *weather,
+		Alerts:   officialAlerts(ctx, provider, loc.Query()),
+	}, nil
+}
+
+// addAirQuality fills in the air quality of weather when the provider did
+// not include it with the conditions. As with officialAlerts a failure only
+// warns.
+func addAirQuality(ctx context.Context, provider WeatherProvider, location string, weather *WeatherData) {
+	if weather.AirQuality != nil {
+		return
+	}
+	airQuality, err := provider.AirQuality(ctx, location)
+	if err != nil && !errors.Is(err, errNotSupported) && !errors.Is(err, errOffline) {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not fetch air quality: %v\n", err)
+	}
+	weather.AirQuality = airQuality
+}
+
+// officialAlerts returns the warnings in force for location. The current
+// conditions endpoints do not carry them, so they cost a request of their
+// own; a failure there only warns.
+func officialAlerts(ctx context.Context, provider WeatherProvider, location string) []WeatherAlert {
+	alerts, err := provider.Alerts(ctx, location)
+	if err != nil && !errors.Is(err, errNotSupported) && !errors.Is(err, errOffline) {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not fetch official alerts: %v\n", err)
+	}
+	return alerts
+}
+
+// exitError attaches an exit code to an error.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+func usageError(format string, args ...any) error {
+	return &exitError{code: exitUsage, err: fmt.Errorf(format, args...)}
+}
+
+func configError(err error) error {
+	return &exitError{code: exitConfig, err: err}
+}
+
+// exitCode maps an error to the exit code for its kind.
+func exitCode(err error) int {
+	var coded *exitError
+	var apiErr *apiError
+	var netErr interface{ Timeout() bool }
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &coded):
+		return coded.code
+	case errors.Is(err, errLocationNotFound):
+		return exitNotFound
+	case errors.Is(err, errNoAPIKey):
+		return exitAuth
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.StatusCode == 401 || apiErr.StatusCode == 403:
+			return exitAuth
+		case apiErr.StatusCode == 404:
+			return exitNotFound
+		case apiErr.retryable():
+			return exitNetwork
+		}
+		return exitFailure
+	case errors.Is(err, errOffline), errors.Is(err, errQuotaExceeded),
+		errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return exitNetwork
+	}
+	return exitFailure
+}
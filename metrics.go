@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics are kept in memory and written in the Prometheus text format; the
+// few metric types needed here do not justify a client library.
+
+// requestDurationBuckets are the upper bounds, in seconds, of the provider
+// request latency histogram.
+var requestDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// metrics is the process-wide registry that apiClient and the readers of
+// weather data report to.
+var metrics = newMetricsRegistry()
+
+type metricsRegistry struct {
+	mu sync.Mutex
+
+	// requests counts provider HTTP requests by provider and status code
+	// ("error" when no response arrived); errors counts failed fetches by
+	// provider and errorType; cache counts cache lookups by provider and
+	// result.
+	requests  map[[2]string]float64
+	errors    map[[2]string]float64
+	cache     map[[2]string]float64
+	durations map[string]*histogram
+
+	// readings holds the latest reading per location.
+	readings map[string]WeatherData
+}
+
+type histogram struct {
+	counts []float64 // per bucket, not cumulative
+	count  float64
+	sum    float64
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		requests:  make(map[[2]string]float64),
+		errors:    make(map[[2]string]float64),
+		cache:     make(map[[2]string]float64),
+		durations: make(map[string]*histogram),
+		readings:  make(map[string]WeatherData),
+	}
+}
+
+// observeRequest records one HTTP attempt against a provider.
+func (m *metricsRegistry) observeRequest(provider string, status int, elapsed time.Duration) {
+	code := "error"
+	if status != 0 {
+		code = strconv.Itoa(status)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[[2]string{provider, code}]++
+
+	h := m.durations[provider]
+	if h == nil {
+		h = &histogram{counts: make([]float64, len(requestDurationBuckets))}
+		m.durations[provider] = h
+	}
+	seconds := elapsed.Seconds()
+	for i, bound := range requestDurationBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// observeError records a fetch that failed after any retries.
+func (m *metricsRegistry) observeError(provider string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors[[2]string{provider, errorType(err)}]++
+}
+
+// observeCache records a cache lookup: "hit" for a fresh copy, "stale" for
+// one that must be revalidated (a 304 response confirms it) and "miss" when
+// nothing is cached.
+func (m *metricsRegistry) observeCache(provider, result string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cache[[2]string{provider, result}]++
+}
+
+// observeReading keeps weather as the latest reading for its location.
+func (m *metricsRegistry) observeReading(weather *WeatherData) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := weather.Location.Name + "\x00" + weather.Location.Country
+	if latest, ok := m.readings[key]; ok && latest.Timestamp.After(weather.Timestamp) {
+		return
+	}
+	m.readings[key] = *weather
+}
+
+// errorType names the kind of a failed fetch for the errors metric.
+func errorType(err error) string {
+	var apiErr *apiError
+	var netErr interface{ Timeout() bool }
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, errQuotaExceeded):
+		return "quota"
+	case errors.Is(err, errOffline):
+		return "offline"
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+			return "auth"
+		case apiErr.StatusCode == http.StatusNotFound:
+			return "not_found"
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return "rate_limited"
+		case apiErr.StatusCode >= 500:
+			return "server"
+		}
+		return "client"
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	}
+	return "other"
+}
+
+// metricsHandler serves the registry, plus quota usage read from the
+// database, at scrape time.
+func metricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		buf := bufio.NewWriter(w)
+		metrics.write(buf)
+		writeQuotaMetrics(buf)
+		buf.Flush()
+	})
+}
+
+// serveMetrics serves /metrics on addr until ctx is cancelled, for commands
+// other than serve.
+func serveMetrics(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("could not serve metrics: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler())
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	return nil
+}
+
+func (m *metricsRegistry) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	readings := make([]WeatherData, 0, len(m.readings))
+	for _, key := range sortedKeys(m.readings) {
+		readings = append(readings, m.readings[key])
+	}
+	gauge := func(name, help string, value func(WeatherData) float64) {
+		writeHeader(w, name, "gauge", help)
+		for _, weather := range readings {
+			writeSample(w, name, value(weather), "location", weather.Location.Name, "country", weather.Location.Country)
+		}
+	}
+	gauge("weather_temperature_celsius", "Latest temperature per location.",
+		func(weather WeatherData) float64 { return weather.TempC })
+	gauge("weather_feels_like_celsius", "Latest feels-like temperature per location.",
+		func(weather WeatherData) float64 { return weather.FeelsLikeC })
+	gauge("weather_humidity_percent", "Latest relative humidity per location.",
+		func(weather WeatherData) float64 { return float64(weather.Humidity) })
+	gauge("weather_wind_speed_meters_per_second", "Latest wind speed per location.",
+		func(weather WeatherData) float64 { return weather.WindKph / 3.6 })
+	gauge("weather_pressure_hectopascals", "Latest sea level pressure per location.",
+		func(weather WeatherData) float64 { return weather.PressureMb })
+	gauge("weather_reading_timestamp_seconds", "Time of the latest reading per location.",
+		func(weather WeatherData) float64 { return float64(weather.Timestamp.Unix()) })
+
+	writeCounter := func(name, help string, counts map[[2]string]float64, labels [2]string) {
+		writeHeader(w, name, "counter", help)
+		keys := make([][2]string, 0, len(counts))
+		for key := range counts {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i][0] < keys[j][0] || keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1]
+		})
+		for _, key := range keys {
+			writeSample(w, name, counts[key], labels[0], key[0], labels[1], key[1])
+		}
+	}
+	writeCounter("weather_provider_requests_total", "HTTP requests sent to providers, by status code.",
+		m.requests, [2]string{"provider", "code"})
+	writeCounter("weather_provider_errors_total", "Fetches that failed after retries, by kind of error.",
+		m.errors, [2]string{"provider", "type"})
+	writeCounter("weather_cache_lookups_total", "Response cache lookups by result (hit, stale or miss).",
+		m.cache, [2]string{"provider", "result"})
+
+	name := "weather_provider_request_duration_seconds"
+	writeHeader(w, name, "histogram", "Latency of HTTP requests to providers.")
+	for _, provider := range sortedKeys(m.durations) {
+		h := m.durations[provider]
+		var cumulative float64
+		for i, bound := range requestDurationBuckets {
+			cumulative += h.counts[i]
+			writeSample(w, name+"_bucket", cumulative, "provider", provider, "le", formatFloat(bound))
+		}
+		writeSample(w, name+"_bucket", h.count, "provider", provider, "le", "+Inf")
+		writeSample(w, name+"_sum", h.sum, "provider", provider)
+		writeSample(w, name+"_count", h.count, "provider", provider)
+	}
+}
+
+// writeQuotaMetrics reports calls made against each provider's daily and
+// monthly limits, as "quota" shows them.
+func writeQuotaMetrics(w io.Writer) {
+	config, _ := loadConfig()
+	path := databasePath(config)
+
+	type usage struct {
+		provider       string
+		daily, monthly int
+		quota          QuotaConfig
+	}
+	var usages []usage
+	for _, provider := range providerNames {
+		quota := quotaFor(config, provider)
+		tracker, err := quotaTrackerFor(path, provider, quota)
+		if err != nil {
+			log.Printf("⚠️  Could not read quota for %s: %v", provider, err)
+			continue
+		}
+		daily, monthly, err := tracker.Usage()
+		if err != nil {
+			log.Printf("⚠️  Could not read quota for %s: %v", provider, err)
+			continue
+		}
+		usages = append(usages, usage{provider, daily, monthly, quota})
+	}
+
+	writeHeader(w, "weather_quota_used_calls", "gauge", "Provider calls made in the current quota window.")
+	for _, u := range usages {
+		writeSample(w, "weather_quota_used_calls", float64(u.daily), "provider", u.provider, "window", "day")
+		writeSample(w, "weather_quota_used_calls", float64(u.monthly), "provider", u.provider, "window", "month")
+	}
+	writeHeader(w, "weather_quota_limit_calls", "gauge", "Provider call limit per quota window; absent when unlimited.")
+	for _, u := range usages {
+		if u.quota.DailyLimit > 0 {
+			writeSample(w, "weather_quota_limit_calls", float64(u.quota.DailyLimit), "provider", u.provider, "window", "day")
+		}
+		if u.quota.MonthlyLimit > 0 {
+			writeSample(w, "weather_quota_limit_calls", float64(u.quota.MonthlyLimit), "provider", u.provider, "window", "month")
+		}
+	}
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// writeSample writes one sample; labels alternate between names and values.
+func writeSample(w io.Writer, name string, value float64, labels ...string) {
+	var pairs []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+`="`+escapeLabel(labels[i+1])+`"`)
+	}
+	if len(pairs) > 0 {
+		name += "{" + strings.Join(pairs, ",") + "}"
+	}
+	fmt.Fprintf(w, "%s %s\n", name, formatSampleValue(value))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatSampleValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return formatFloat(value)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// The canonical model is provider-neutral and always stored in metric units;
+// every field that carries a unit names it in its suffix:
+//
+//	C   degrees Celsius
+//	Kph kilometres per hour
+//	Mb  millibars (hPa)
+//	Mm  millimetres
+//	Ug  micrograms per cubic metre (µg/m³)
+//	Gm3 grams per cubic metre (g/m³)
+//
+// Humidity and chance of rain are percentages and wind degrees are measured
+// clockwise from north. Providers convert their raw payloads into these types
+// before anything else in the tool sees them.
+
+// Location describes the place a reading or forecast belongs to.
+type Location struct {
+	Name     string  `json:"name"`
+	Region   string  `json:"region,omitempty"`
+	Country  string  `json:"country"`
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+	Timezone string  `json:"timezone,omitempty"`
+}
+
+// localTime converts t to the location's timezone when it is known.
+func (l Location) localTime(t time.Time) time.Time {
+	if l.Timezone == "" {
+		return t
+	}
+	if zone, err := time.LoadLocation(l.Timezone); err == nil {
+		return t.In(zone)
+	}
+	return t
+}
+
+// WeatherData is a single provider-neutral weather observation.
+type WeatherData struct {
+	Location   Location  `json:"location"`
+	Timestamp  time.Time `json:"timestamp"`
+	TempC      float64   `json:"temp_c"`
+	FeelsLikeC float64   `json:"feelslike_c"`
+	Humidity   int       `json:"humidity"`
+	WindKph    float64   `json:"wind_kph"`
+	WindDegree int       `json:"wind_degree"`
+	PressureMb float64   `json:"pressure_mb"`
+	PrecipMm   float64   `json:"precip_mm"`
+	Condition  string    `json:"condition"`
+
+	// AirQuality is nil when the provider has no air quality data.
+	AirQuality *AirQuality `json:"air_quality,omitempty"`
+}
+
+// AirQuality holds pollutant concentrations near the ground; see aqi.go for
+// how they are rated.
+type AirQuality struct {
+	PM25Ug float64 `json:"pm2_5_ug"`
+	PM10Ug float64 `json:"pm10_ug"`
+	O3Ug   float64 `json:"o3_ug"`
+	NO2Ug  float64 `json:"no2_ug"`
+	SO2Ug  float64 `json:"so2_ug"`
+	COUg   float64 `json:"co_ug"`
+}
+
+// ForecastHour is the expected weather for a single forecast step. Providers
+// with coarser steps (OpenWeatherMap uses three hours) report one entry per
+// step.
+type ForecastHour struct {
+	Time         time.Time `json:"time"`
+	TempC        float64   `json:"temp_c"`
+	FeelsLikeC   float64   `json:"feelslike_c"`
+	Humidity     int       `json:"humidity"`
+	WindKph      float64   `json:"wind_kph"`
+	PrecipMm     float64   `json:"precip_mm"`
+	ChanceOfRain int       `json:"chance_of_rain"`
+	Condition    string    `json:"condition"`
+}
+
+// ForecastDay summarises the expected temperatures for one calendar day.
+type ForecastDay struct {
+	Date      time.Time      `json:"date"`
+	MaxTempC  float64        `json:"maxtemp_c"`
+	MinTempC  float64        `json:"mintemp_c"`
+	AvgTempC  float64        `json:"avgtemp_c"`
+	Condition string         `json:"condition"`
+	Hours     []ForecastHour `json:"hours,omitempty"`
+}
+
+// Forecast bundles the current conditions with the upcoming days and any
+// official warnings for the location.
+type Forecast struct {
+	Location Location       `json:"location"`
+	Current  WeatherData    `json:"current"`
+	Days     []ForecastDay  `json:"days"`
+	Alerts   []WeatherAlert `json:"alerts,omitempty"`
+}
+
+// WeatherAlert is a severe weather warning issued by a weather agency and
+// relayed by the provider, as opposed to the tool's own alert rules.
+// Severity, Urgency and Certainty use Common Alerting Protocol values;
+// Severity is one of warningSeverities.
+type WeatherAlert struct {
+	Event       string    `json:"event"`
+	Headline    string    `json:"headline"`
+	Severity    string    `json:"severity"`
+	Urgency     string    `json:"urgency,omitempty"`
+	Certainty   string    `json:"certainty,omitempty"`
+	Areas       []string  `json:"areas,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Instruction string    `json:"instruction,omitempty"`
+	Effective   time.Time `json:"effective"`
+	Expires     time.Time `json:"expires"` // zero when open-ended
+}
+
+// warningSeverities are the CAP severities in increasing order; an alert's
+// level is its index plus one, so that level 0 means no warning at all.
+var warningSeverities = []string{"Unknown", "Minor", "Moderate", "Severe", "Extreme"}
+
+// normalizeSeverity maps a provider's severity onto warningSeverities.
+func normalizeSeverity(severity string) string {
+	for _, known := range warningSeverities {
+		if strings.EqualFold(strings.TrimSpace(severity), known) {
+			return known
+		}
+	}
+	return "Unknown"
+}
+
+func (a WeatherAlert) level() int {
+	return slices.Index(warningSeverities, normalizeSeverity(a.Severity)) + 1
+}
+
+// inForce reports whether the warning has not expired at t.
+func (a WeatherAlert) inForce(t time.Time) bool {
+	return a.Expires.IsZero() || a.Expires.After(t)
+}
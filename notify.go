@@ -0,0 +1,529 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	sinkWebhook = "webhook"
+	sinkEmail   = "email"
+	sinkCommand = "command"
+
+	templateJSON  = "json"
+	templateSlack = "slack"
+	templateTeams = "teams"
+
+	// notifyTimeout limits each delivery attempt.
+	notifyTimeout = 30 * time.Second
+
+	notifyBaseDelay = 2 * time.Second
+	notifyMaxDelay  = 30 * time.Second
+
+	// deliveryLogRetention is how long the delivery log is kept.
+	deliveryLogRetention = 90 * 24 * time.Hour
+)
+
+var sinkTypes = []string{sinkWebhook, sinkEmail, sinkCommand}
+
+// NotificationSink is somewhere alert events are delivered: a webhook, an
+// email address list or a local command.
+type NotificationSink struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // webhook, email or command
+
+	// URL receives a POST per event. Template shapes the body: "json" (the
+	// default) sends an alerts document as "alerts -output json" prints it,
+	// "slack" and "teams" send a message those services display, and
+	// anything else is a Go text/template executed with the event, with
+	// the functions json (encode a value) and line (the one-line message).
+	URL      string            `json:"url,omitempty"`
+	Template string            `json:"template,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+
+	// SMTPAddr is the mail server as host:port. STARTTLS is used when the
+	// server offers it; Password falls back to WEATHER_SMTP_PASSWORD.
+	SMTPAddr string   `json:"smtp_addr,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+
+	// Command is run per event with the message as its last argument and
+	// the event as JSON on stdin, e.g. "notify-send Weather" for a desktop
+	// notification.
+	Command string `json:"command,omitempty"`
+
+	// MinSeverity and Rules limit which events are sent; by default every
+	// event is.
+	MinSeverity string   `json:"min_severity,omitempty"`
+	Rules       []string `json:"rules,omitempty"`
+}
+
+// wants reports whether event passes the sink's filters.
+func (s NotificationSink) wants(event AlertEvent) bool {
+	if len(s.Rules) > 0 && !slices.Contains(s.Rules, event.Rule) {
+		return false
+	}
+	if s.MinSeverity != "" {
+		return slices.Index(alertSeverities, event.Severity) >= slices.Index(alertSeverities, s.MinSeverity)
+	}
+	return true
+}
+
+// validateNotifications reports problems with the notifications config key.
+func validateNotifications(sinks []NotificationSink, rules []AlertRule, invalid func(key, format string, args ...any)) {
+	seen := make(map[string]bool)
+	for i, sink := range sinks {
+		key := fmt.Sprintf("notifications[%d]", i)
+		if sink.Name == "" {
+			invalid(key+".name", "is required")
+		} else if seen[strings.ToLower(sink.Name)] {
+			invalid(key+".name", "%q is used more than once", sink.Name)
+		}
+		seen[strings.ToLower(sink.Name)] = true
+
+		switch sink.Type {
+		case sinkWebhook:
+			if u, err := url.Parse(sink.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				invalid(key+".url", "must be an http or https URL")
+			}
+			if _, err := webhookTemplate(sink.Template); err != nil {
+				invalid(key+".template", "%v", err)
+			}
+		case sinkEmail:
+			if _, _, err := net.SplitHostPort(sink.SMTPAddr); err != nil {
+				invalid(key+".smtp_addr", "%q is not host:port, e.g. \"smtp.example.com:587\"", sink.SMTPAddr)
+			}
+			if sink.From == "" {
+				invalid(key+".from", "is required")
+			}
+			if len(sink.To) == 0 {
+				invalid(key+".to", "needs at least one address")
+			}
+		case sinkCommand:
+			if strings.TrimSpace(sink.Command) == "" {
+				invalid(key+".command", "is required")
+			}
+		default:
+			invalid(key+".type", "must be one of %s, got %q", strings.Join(sinkTypes, ", "), sink.Type)
+		}
+
+		if sink.MinSeverity != "" && !slices.Contains(alertSeverities, sink.MinSeverity) {
+			invalid(key+".min_severity", "must be one of %s, got %q", strings.Join(alertSeverities, ", "), sink.MinSeverity)
+		}
+		for _, name := range sink.Rules {
+			if !slices.ContainsFunc(rules, func(rule AlertRule) bool { return rule.Name == name }) {
+				invalid(key+".rules", "no alert rule is named %q", name)
+			}
+		}
+	}
+}
+
+// redactedSink hides the sink's secrets: its password, header values and
+// the path and query of its URL, which for Slack and Teams is the secret.
+func redactedSink(sink NotificationSink) NotificationSink {
+	sink.Password = redact(sink.Password)
+	sink.URL = redactURL(sink.URL)
+	if len(sink.Headers) > 0 {
+		headers := make(map[string]string, len(sink.Headers))
+		for name, value := range sink.Headers {
+			headers[name] = redact(value)
+		}
+		sink.Headers = headers
+	}
+	return sink
+}
+
+// redactURL keeps only the scheme and host of rawURL.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return redact(rawURL)
+	}
+	if u.Path == "" && u.RawQuery == "" {
+		return u.Scheme + "://" + u.Host
+	}
+	return u.Scheme + "://" + u.Host + "/[redacted]"
+}
+
+// permanentError marks a delivery failure that retrying cannot fix, such as
+// a rejected request or a bad template.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// send makes one attempt to deliver event to the sink.
+func (s NotificationSink) send(ctx context.Context, client *http.Client, event AlertEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+
+	switch s.Type {
+	case sinkWebhook:
+		return s.postWebhook(ctx, client, event)
+	case sinkEmail:
+		return s.sendEmail(ctx, event)
+	case sinkCommand:
+		return s.runCommand(ctx, event)
+	}
+	return &permanentError{fmt.Errorf("unknown sink type %q", s.Type)}
+}
+
+// webhookTemplate returns the template for a webhook body; nil means the
+// JSON alerts document.
+func webhookTemplate(name string) (*template.Template, error) {
+	switch name {
+	case "", templateJSON:
+		return nil, nil
+	case templateSlack:
+		name = `{"text": {{line . | json}}}`
+	case templateTeams:
+		name = `{"@type": "MessageCard", "@context": "https://schema.org/extensions",` +
+			` "themeColor": {{if eq .Status "resolved"}}"2EB886"{{else if eq .Severity "critical"}}"D70000"{{else}}"FFA500"{{end}},` +
+			` "summary": {{json .Message}}, "title": {{printf "Weather alert %s: %s" .Status .Rule | json}},` +
+			` "text": {{line . | json}}}`
+	}
+	return template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+		"line": formatAlertEvent,
+	}).Parse(name)
+}
+
+func (s NotificationSink) postWebhook(ctx context.Context, client *http.Client, event AlertEvent) error {
+	var body bytes.Buffer
+	tmpl, err := webhookTemplate(s.Template)
+	if err != nil {
+		return &permanentError{err}
+	}
+	if tmpl == nil {
+		err = json.NewEncoder(&body).Encode(newAlertsDocument([]AlertEvent{event}))
+	} else {
+		err = tmpl.Execute(&body, event)
+	}
+	if err != nil {
+		return &permanentError{fmt.Errorf("could not render payload: %v", err)}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, &body)
+	if err != nil {
+		return &permanentError{fmt.Errorf("invalid webhook URL %s", redactURL(s.URL))}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL is left out of the message: it often is the secret.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("POST %s: %w", redactURL(s.URL), err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("POST %s: %s", redactURL(s.URL), resp.Status)
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusRequestTimeout {
+			return &permanentError{err}
+		}
+		return err
+	}
+	return nil
+}
+
+func (s NotificationSink) sendEmail(ctx context.Context, event AlertEvent) error {
+	host, _, _ := net.SplitHostPort(s.SMTPAddr) // validated by loadConfig
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", s.SMTPAddr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		password := s.Password
+		if password == "" {
+			password = os.Getenv(envPrefix + "SMTP_PASSWORD")
+		}
+		// PlainAuth refuses to send the password unencrypted, except to
+		// localhost
+		if err := client.Auth(smtp.PlainAuth("", s.Username, password, host)); err != nil {
+			return smtpError(err)
+		}
+	}
+
+	if err := client.Mail(s.From); err != nil {
+		return smtpError(err)
+	}
+	for _, to := range s.To {
+		if err := client.Rcpt(to); err != nil {
+			return smtpError(err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return smtpError(err)
+	}
+	if _, err := w.Write(s.emailMessage(event)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return smtpError(err)
+	}
+	return client.Quit()
+}
+
+// smtpError makes rejections (5xx replies) permanent.
+func smtpError(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+		return &permanentError{err}
+	}
+	return err
+}
+
+// emailMessage renders event as a plain text email.
+func (s NotificationSink) emailMessage(event AlertEvent) []byte {
+	subject := fmt.Sprintf("[%s] Weather alert %s: %s at %s", strings.ToUpper(event.Severity), event.Status,
+		event.Rule, event.Location.DisplayName())
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	body := quotedprintable.NewWriter(&msg)
+	fmt.Fprintf(body, "%s\r\n\r\n", formatAlertEvent(event))
+	fmt.Fprintf(body, "Rule:      %s\r\n", event.Rule)
+	fmt.Fprintf(body, "Status:    %s\r\n", event.Status)
+	fmt.Fprintf(body, "Severity:  %s\r\n", event.Severity)
+	fmt.Fprintf(body, "Location:  %s\r\n", event.Location.DisplayName())
+	fmt.Fprintf(body, "Metric:    %s %s %g (metric units)\r\n", event.Metric, event.Operator, event.Threshold)
+	fmt.Fprintf(body, "Value:     %g at %s\r\n", event.Value, formatTime(event.At))
+	fmt.Fprintf(body, "Since:     %s\r\n", formatTime(event.Since))
+	if w := event.Warning; w != nil {
+		fmt.Fprintf(body, "\r\n%s (%s, %s)\r\n", w.Headline, w.Severity, w.Urgency)
+		if len(w.Areas) > 0 {
+			fmt.Fprintf(body, "Areas:     %s\r\n", strings.Join(w.Areas, "; "))
+		}
+		fmt.Fprintf(body, "Effective: %s\r\nExpires:   %s\r\n", formatTime(w.Effective), formatTime(w.Expires))
+		if w.Description != "" {
+			fmt.Fprintf(body, "\r\n%s\r\n", w.Description)
+		}
+		if w.Instruction != "" {
+			fmt.Fprintf(body, "\r\n%s\r\n", w.Instruction)
+		}
+	}
+	body.Close()
+	return msg.Bytes()
+}
+
+// runCommand runs the sink's command with the message as its last argument,
+// the event as JSON on stdin and its main fields in WEATHER_ALERT_*
+// variables.
+func (s NotificationSink) runCommand(ctx context.Context, event AlertEvent) error {
+	args := strings.Fields(s.Command)
+	input, err := json.Marshal(event)
+	if err != nil {
+		return &permanentError{err}
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], append(args[1:], formatAlertEvent(event))...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		envPrefix+"ALERT_RULE="+event.Rule,
+		envPrefix+"ALERT_STATUS="+event.Status,
+		envPrefix+"ALERT_SEVERITY="+event.Severity,
+		envPrefix+"ALERT_LOCATION="+event.Location.DisplayName(),
+		envPrefix+"ALERT_MESSAGE="+event.Message,
+	)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v", args[0], err)
+	}
+	return nil
+}
+
+const deliverySchema = `
+CREATE TABLE IF NOT EXISTS alert_deliveries (
+	time     INTEGER NOT NULL,
+	sink     TEXT    NOT NULL,
+	rule     TEXT    NOT NULL,
+	location TEXT    NOT NULL,
+	status   TEXT    NOT NULL,
+	attempts INTEGER NOT NULL,
+	error    TEXT    NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_alert_deliveries_time ON alert_deliveries (time);
+`
+
+// Delivery is a row of the delivery log: one event sent, or given up on,
+// by one sink.
+type Delivery struct {
+	Time      time.Time `json:"time"`
+	Sink      string    `json:"sink"`
+	Rule      string    `json:"rule"`
+	Location  string    `json:"location"`
+	Status    string    `json:"status"` // the event's: firing or resolved
+	Attempts  int       `json:"attempts"`
+	Delivered bool      `json:"delivered"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// notifier delivers alert events to the configured sinks, retrying failed
+// attempts with backoff, and logs the outcome of every delivery.
+type notifier struct {
+	sinks       []NotificationSink
+	db          *sql.DB
+	http        *http.Client
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+func newNotifier(config Config) (*notifier, error) {
+	db, err := openDatabase(databasePath(config), deliverySchema)
+	if err != nil {
+		return nil, err
+	}
+	return &notifier{
+		sinks:       config.Notifications,
+		db:          db,
+		http:        &http.Client{},
+		maxAttempts: config.MaxRetries + 1,
+		baseDelay:   notifyBaseDelay,
+		maxDelay:    notifyMaxDelay,
+	}, nil
+}
+
+func (n *notifier) Close() error {
+	return n.db.Close()
+}
+
+// Notify delivers event to every sink that wants it. A failed delivery
+// does not stop the others; the failures are returned together.
+func (n *notifier) Notify(ctx context.Context, event AlertEvent) error {
+	var errs []error
+	for _, sink := range n.sinks {
+		if !sink.wants(event) {
+			continue
+		}
+		if err := n.deliver(ctx, sink, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver sends event to sink, retrying until it succeeds, fails
+// permanently or runs out of attempts, and logs the outcome.
+func (n *notifier) deliver(ctx context.Context, sink NotificationSink, event AlertEvent) error {
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		err = sink.send(ctx, n.http, event)
+		var permanent *permanentError
+		if err == nil || errors.As(err, &permanent) || attempt >= n.maxAttempts || ctx.Err() != nil {
+			break
+		}
+
+		timer := time.NewTimer(fullJitter(n.baseDelay, n.maxDelay, attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+	logErr := n.record(sink, event, attempt, err)
+	if err != nil {
+		err = fmt.Errorf("notify %s of %s: %v", sink.Name, event.Rule, err)
+	}
+	return errors.Join(err, logErr)
+}
+
+func (n *notifier) record(sink NotificationSink, event AlertEvent, attempts int, deliveryErr error) error {
+	now := time.Now()
+	message := ""
+	if deliveryErr != nil {
+		message = deliveryErr.Error()
+	}
+	_, err := n.db.Exec(`INSERT INTO alert_deliveries (time, sink, rule, location, status, attempts, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		now.Unix(), sink.Name, event.Rule, event.Location.DisplayName(), event.Status, attempts, message)
+	if err == nil {
+		_, err = n.db.Exec(`DELETE FROM alert_deliveries WHERE time < ?`, now.Add(-deliveryLogRetention).Unix())
+	}
+	if err != nil {
+		return fmt.Errorf("could not log delivery: %v", err)
+	}
+	return nil
+}
+
+// deliveries returns the latest limit entries of the delivery log, oldest
+// first.
+func (n *notifier) deliveries(limit int) ([]Delivery, error) {
+	rows, err := n.db.Query(`SELECT time, sink, rule, location, status, attempts, error FROM (
+		SELECT rowid, * FROM alert_deliveries ORDER BY time DESC, rowid DESC LIMIT ?
+	) ORDER BY time, rowid`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not read delivery log: %v", err)
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		var d Delivery
+		var at int64
+		if err := rows.Scan(&at, &d.Sink, &d.Rule, &d.Location, &d.Status, &d.Attempts, &d.Error); err != nil {
+			return nil, err
+		}
+		d.Time = time.Unix(at, 0)
+		d.Delivered = d.Error == ""
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
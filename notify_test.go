@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testEvent = AlertEvent{
+	Rule:      "frost",
+	Status:    alertFiring,
+	Severity:  "critical",
+	Location:  Location{Name: "Yard"},
+	Metric:    "temp",
+	Operator:  alertBelow,
+	Threshold: 0,
+	Value:     -3,
+	Since:     time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC),
+	Message:   "frost: temp below 0.0°C at Yard (-3.0°C now)",
+}
+
+// testNotifier returns a notifier with a delivery log in a temporary
+// database and no delay between attempts.
+func testNotifier(t *testing.T, maxAttempts int, sinks ...NotificationSink) *notifier {
+	t.Helper()
+	db, err := openDatabase(filepath.Join(t.TempDir(), "weather.db"), deliverySchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &notifier{
+		sinks:       sinks,
+		db:          db,
+		http:        &http.Client{},
+		maxAttempts: maxAttempts,
+		baseDelay:   time.Millisecond,
+		maxDelay:    time.Millisecond,
+	}
+}
+
+// webhookServer records the requests it receives and answers each with
+// the next status in statuses, then 200.
+type webhookServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	bodies   []string
+	headers  []http.Header
+	statuses []int
+}
+
+func newWebhookServer(t *testing.T, statuses ...int) *webhookServer {
+	t.Helper()
+	server := &webhookServer{statuses: statuses}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		server.bodies = append(server.bodies, string(body))
+		server.headers = append(server.headers, r.Header.Clone())
+		if len(server.statuses) > 0 {
+			w.WriteHeader(server.statuses[0])
+			server.statuses = server.statuses[1:]
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *webhookServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+func TestWebhookTemplates(t *testing.T) {
+	server := newWebhookServer(t)
+	sinks := []NotificationSink{
+		{Name: "doc", Type: sinkWebhook, URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}},
+		{Name: "slack", Type: sinkWebhook, URL: server.URL, Template: templateSlack},
+		{Name: "teams", Type: sinkWebhook, URL: server.URL, Template: templateTeams},
+		{Name: "custom", Type: sinkWebhook, URL: server.URL, Template: `{"rule": {{json .Rule}}, "value": {{.Value}}}`},
+	}
+	n := testNotifier(t, 1, sinks...)
+	if err := n.Notify(context.Background(), testEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	bodies := server.requests()
+	if len(bodies) != len(sinks) {
+		t.Fatalf("got %d requests, want %d", len(bodies), len(sinks))
+	}
+	if got := server.headers[0].Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization = %q", got)
+	}
+
+	var doc struct {
+		Kind   string       `json:"kind"`
+		Events []AlertEvent `json:"events"`
+	}
+	if err := json.Unmarshal([]byte(bodies[0]), &doc); err != nil {
+		t.Fatalf("json body: %v\n%s", err, bodies[0])
+	}
+	if doc.Kind != "alerts" || len(doc.Events) != 1 || doc.Events[0].Rule != "frost" {
+		t.Errorf("json body = %+v", doc)
+	}
+
+	var slack struct{ Text string }
+	if err := json.Unmarshal([]byte(bodies[1]), &slack); err != nil || !strings.Contains(slack.Text, testEvent.Message) {
+		t.Errorf("slack body = %s (%v)", bodies[1], err)
+	}
+
+	var teams map[string]string
+	if err := json.Unmarshal([]byte(bodies[2]), &teams); err != nil {
+		t.Fatalf("teams body: %v\n%s", err, bodies[2])
+	}
+	if teams["@type"] != "MessageCard" || teams["themeColor"] != "D70000" || teams["title"] != "Weather alert firing: frost" {
+		t.Errorf("teams body = %v", teams)
+	}
+
+	if bodies[3] != `{"rule": "frost", "value": -3}` {
+		t.Errorf("custom body = %s", bodies[3])
+	}
+
+	deliveries, err := n.deliveries(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != len(sinks) || !deliveries[0].Delivered || deliveries[0].Attempts != 1 {
+		t.Errorf("deliveries = %+v", deliveries)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	t.Run("server error", func(t *testing.T) {
+		server := newWebhookServer(t, http.StatusBadGateway)
+		n := testNotifier(t, 3, NotificationSink{Name: "hook", Type: sinkWebhook, URL: server.URL})
+		if err := n.Notify(context.Background(), testEvent); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(server.requests()); got != 2 {
+			t.Errorf("got %d attempts, want 2", got)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		server := newWebhookServer(t, http.StatusBadRequest)
+		n := testNotifier(t, 3, NotificationSink{Name: "hook", Type: sinkWebhook, URL: server.URL + "/secret-path"})
+		err := n.Notify(context.Background(), testEvent)
+		if err == nil {
+			t.Fatal("delivery succeeded")
+		}
+		if strings.Contains(err.Error(), "secret-path") {
+			t.Errorf("error leaks the webhook path: %v", err)
+		}
+		if got := len(server.requests()); got != 1 {
+			t.Errorf("got %d attempts, want 1", got)
+		}
+		deliveries, _ := n.deliveries(10)
+		if len(deliveries) != 1 || deliveries[0].Delivered {
+			t.Errorf("deliveries = %+v", deliveries)
+		}
+	})
+}
+
+// smtpStandIn is a minimal SMTP server that accepts one message per
+// connection. rejectRcpt makes it refuse every recipient.
+type smtpStandIn struct {
+	addr       string
+	rejectRcpt bool
+
+	mu       sync.Mutex
+	messages []string
+	rcpts    []string
+	sessions atomic.Int32
+}
+
+func newSMTPStandIn(t *testing.T, rejectRcpt bool) *smtpStandIn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s := &smtpStandIn{addr: listener.Addr().String(), rejectRcpt: rejectRcpt}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	s.sessions.Add(1)
+	r := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			if s.rejectRcpt {
+				reply("550 5.1.1 No such user")
+				continue
+			}
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, strings.TrimSpace(line[len("RCPT TO:"):]))
+			s.mu.Unlock()
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 OK queued")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestEmailDelivery(t *testing.T) {
+	server := newSMTPStandIn(t, false)
+	sink := NotificationSink{Name: "mail", Type: sinkEmail, SMTPAddr: server.addr,
+		From: "weather@example.com", To: []string{"yard@example.com", "ops@example.com"}}
+	n := testNotifier(t, 1, sink)
+
+	if err := n.Notify(context.Background(), testEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(server.messages))
+	}
+	if strings.Join(server.rcpts, " ") != "<yard@example.com> <ops@example.com>" {
+		t.Errorf("recipients = %v", server.rcpts)
+	}
+	message := server.messages[0]
+	for _, want := range []string{
+		"From: weather@example.com",
+		"To: yard@example.com, ops@example.com",
+		"Subject: [CRITICAL] Weather alert firing: frost at Yard",
+		"Content-Transfer-Encoding: quoted-printable",
+		"frost: temp below 0.0",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("message lacks %q:\n%s", want, message)
+		}
+	}
+}
+
+func TestEmailRejected(t *testing.T) {
+	server := newSMTPStandIn(t, true)
+	sink := NotificationSink{Name: "mail", Type: sinkEmail, SMTPAddr: server.addr,
+		From: "weather@example.com", To: []string{"nobody@example.com"}}
+	n := testNotifier(t, 3, sink)
+
+	err := n.Notify(context.Background(), testEvent)
+	if err == nil || !strings.Contains(err.Error(), "No such user") {
+		t.Fatalf("err = %v, want the rejection", err)
+	}
+	var permanent *permanentError
+	if errors.As(sink.send(context.Background(), n.http, testEvent), &permanent) == false {
+		t.Error("rejection is not permanent")
+	}
+	// One session from Notify, which does not retry, and one from send.
+	if got := server.sessions.Load(); got != 2 {
+		t.Errorf("got %d sessions, want 2", got)
+	}
+}
+
+func TestCommandHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook is a shell script")
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "hook.sh")
+	body := "#!/bin/sh\ncat > " + dir + "/event.json\nprintf '%s|%s' \"$1\" \"$" + envPrefix + "ALERT_RULE\" > " + dir + "/args\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	n := testNotifier(t, 1, NotificationSink{Name: "hook", Type: sinkCommand, Command: script})
+	if err := n.Notify(context.Background(), testEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	var event AlertEvent
+	data, err := os.ReadFile(filepath.Join(dir, "event.json"))
+	if err != nil || json.Unmarshal(data, &event) != nil || event.Rule != "frost" {
+		t.Errorf("stdin = %s (%v)", data, err)
+	}
+	args, err := os.ReadFile(filepath.Join(dir, "args"))
+	if err != nil || !strings.HasSuffix(string(args), testEvent.Message+"|frost") {
+		t.Errorf("args = %q (%v)", args, err)
+	}
+}
+
+func TestSinkFilters(t *testing.T) {
+	tests := []struct {
+		sink NotificationSink
+		want bool
+	}{
+		{NotificationSink{}, true},
+		{NotificationSink{MinSeverity: "warning"}, true},
+		{NotificationSink{MinSeverity: "critical"}, true},
+		{NotificationSink{Rules: []string{"frost"}}, true},
+		{NotificationSink{Rules: []string{"heat"}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.sink.wants(testEvent); got != tt.want {
+			t.Errorf("%+v wants = %v, want %v", tt.sink, got, tt.want)
+		}
+	}
+	info := testEvent
+	info.Severity = "info"
+	if (NotificationSink{MinSeverity: "warning"}).wants(info) {
+		t.Error("an info event passed min_severity warning")
+	}
+}
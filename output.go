@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputText  = "text"
+	outputTable = "table"
+	outputCSV   = "csv"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+
+	// outputSchemaVersion is raised whenever a field or column of a
+	// structured document is renamed, removed or changes meaning. New fields
+	// and trailing columns may be added without raising it.
+	outputSchemaVersion = 1
+)
+
+var outputFormats = []string{outputText, outputTable, outputCSV, outputJSON, outputYAML}
+
+var yaml11Bools = map[string]bool{
+	"y": true, "yes": true, "n": true, "no": true, "on": true, "off": true,
+}
+
+// addOutputFlag registers the -output flag on a command's flag set.
+func addOutputFlag(flags *flag.FlagSet) *string {
+	return flags.String("output", outputText, "output format: "+strings.Join(outputFormats, ", "))
+}
+
+func checkOutputFormat(format string) error {
+	if !slices.Contains(outputFormats, format) {
+		return usageError("unknown output format %q; use one of %s", format, strings.Join(outputFormats, ", "))
+	}
+	return nil
+}
+
+// Structured documents always use the canonical metric model, whatever
+// --units says: field and column names carry their unit, so consumers never
+// need to know how the tool was configured.
+
+// documentUnits names the unit behind every field name suffix, and of the
+// unsuffixed fields that have one, so documents describe themselves.
+var documentUnits = map[string]string{
+	"_c":             "°C",
+	"_kph":           "km/h",
+	"_mb":            "hPa",
+	"_mm":            "mm",
+	"_ug":            "µg/m³",
+	"_gm3":           "g/m³",
+	"humidity":       "%",
+	"chance_of_rain": "%",
+	"wind_degree":    "°",
+}
+
+// documentHeader starts every JSON and YAML document.
+type documentHeader struct {
+	SchemaVersion int               `json:"schema_version"`
+	Kind          string            `json:"kind"`
+	GeneratedAt   time.Time         `json:"generated_at"`
+	Units         map[string]string `json:"units"`
+}
+
+func newDocumentHeader(kind string) documentHeader {
+	return documentHeader{SchemaVersion: outputSchemaVersion, Kind: kind, GeneratedAt: time.Now().UTC(),
+		Units: documentUnits}
+}
+
+// document is a structured command result. Its table form is used for the
+// csv and table formats; the header row is part of the schema.
+type document interface {
+	table() (header []string, rows [][]string)
+}
+
+type currentDocument struct {
+	documentHeader
+	Location        Location         `json:"location"`
+	Current         WeatherData      `json:"current"`
+	Derived         DerivedMetrics   `json:"derived"`
+	AirQualityIndex *AirQualityIndex `json:"air_quality_index,omitempty"`
+	Alerts          []WeatherAlert   `json:"alerts"`
+}
+
+func newCurrentDocument(weather *WeatherData, alerts []WeatherAlert) currentDocument {
+	if alerts == nil {
+		alerts = []WeatherAlert{}
+	}
+	return currentDocument{newDocumentHeader("current"), weather.Location, *weather, weather.Derived(),
+		airQualityIndex(weather), alerts}
+}
+
+// airQualityIndex rates the air quality of weather, if it has any.
+func airQualityIndex(weather *WeatherData) *AirQualityIndex {
+	if weather.AirQuality == nil {
+		return nil
+	}
+	index := weather.AirQuality.Index()
+	return &index
+}
+
+// table has one row; the air quality columns are empty when there is no
+// air quality data.
+func (d currentDocument) table() ([]string, [][]string) {
+	c := d.Current
+	row := []string{d.Location.Name, formatFloat(d.Location.Lat), formatFloat(d.Location.Lon),
+		formatTime(c.Timestamp), formatFloat(c.TempC), formatFloat(c.FeelsLikeC), strconv.Itoa(c.Humidity),
+		formatFloat(c.WindKph), strconv.Itoa(c.WindDegree), formatFloat(c.PressureMb), formatFloat(c.PrecipMm),
+		c.Condition}
+	if aq := c.AirQuality; aq != nil {
+		index := aq.Index()
+		row = append(row, formatFloat(aq.PM25Ug), formatFloat(aq.PM10Ug), formatFloat(aq.O3Ug),
+			formatFloat(aq.NO2Ug), formatFloat(aq.SO2Ug), formatFloat(aq.COUg),
+			strconv.Itoa(index.USEPA), strconv.Itoa(index.UKDEFRA))
+	} else {
+		row = append(row, make([]string, 8)...)
+	}
+	derived := c.Derived()
+	row = append(row, formatOptional(derived.DewPointC), formatOptional(derived.HeatIndexC), formatFloat(derived.WindChillC),
+		formatOptional(derived.Humidex), formatOptional(derived.WetBulbC), formatOptional(derived.AbsoluteHumidityGm3))
+	return []string{"location", "lat", "lon", "timestamp", "temp_c", "feelslike_c", "humidity",
+			"wind_kph", "wind_degree", "pressure_mb", "precip_mm", "condition",
+			"pm2_5_ug", "pm10_ug", "o3_ug", "no2_ug", "so2_ug", "co_ug", "us_epa_aqi", "uk_defra_daqi",
+			"dewpoint_c", "heat_index_c", "wind_chill_c", "humidex", "wetbulb_c", "absolute_humidity_gm3"},
+		[][]string{row}
+}
+
+type forecastDocument struct {
+	documentHeader
+	Location        Location            `json:"location"`
+	Current         WeatherData         `json:"current"`
+	Derived         DerivedMetrics      `json:"derived"`
+	AirQualityIndex *AirQualityIndex    `json:"air_quality_index,omitempty"`
+	Analysis        TemperatureAnalysis `json:"analysis"`
+	Days            []ForecastDay       `json:"days"`
+	Alerts          []WeatherAlert      `json:"alerts"`
+}
+
+func newForecastDocument(forecast *Forecast) forecastDocument {
+	alerts := forecast.Alerts
+	if alerts == nil {
+		alerts = []WeatherAlert{}
+	}
+	return forecastDocument{newDocumentHeader("forecast"), forecast.Location, forecast.Current,
+		forecast.Current.Derived(), airQualityIndex(&forecast.Current), AnalyzeTemperatures(*forecast), forecast.Days, alerts}
+}
+
+// table lists one row per day; hours and official alerts are only in the
+// JSON and YAML forms.
+func (d forecastDocument) table() ([]string, [][]string) {
+	header := []string{"location", "date", "maxtemp_c", "mintemp_c", "avgtemp_c", "condition"}
+	var rows [][]string
+	for _, day := range d.Days {
+		rows = append(rows, []string{d.Location.Name, day.Date.Format(time.DateOnly),
+			formatFloat(day.MaxTempC), formatFloat(day.MinTempC), formatFloat(day.AvgTempC), day.Condition})
+	}
+	return header, rows
+}
+
+type historyDocument struct {
+	documentHeader
+	Location Location    `json:"location"`
+	Day      ForecastDay `json:"day"`
+}
+
+func newHistoryDocument(location Location, day ForecastDay) historyDocument {
+	return historyDocument{newDocumentHeader("history"), location, day}
+}
+
+// table lists one row per recorded hour.
+func (d historyDocument) table() ([]string, [][]string) {
+	header := []string{"location", "time", "temp_c", "feelslike_c", "humidity", "wind_kph",
+		"precip_mm", "chance_of_rain", "condition"}
+	var rows [][]string
+	for _, hour := range d.Day.Hours {
+		rows = append(rows, []string{d.Location.Name, formatTime(hour.Time), formatFloat(hour.TempC),
+			formatFloat(hour.FeelsLikeC), strconv.Itoa(hour.Humidity), formatFloat(hour.WindKph),
+			formatFloat(hour.PrecipMm), strconv.Itoa(hour.ChanceOfRain), hour.Condition})
+	}
+	return header, rows
+}
+
+type analysisDocument struct {
+	documentHeader
+	Location Location       `json:"location"`
+	Period   string         `json:"period"`
+	Analysis AnalysisResult `json:"analysis"`
+	Readings []WeatherData  `json:"readings"`
+}
+
+func newAnalysisDocument(location Location, period time.Duration, readings []WeatherData) analysisDocument {
+	if readings == nil {
+		readings = []WeatherData{}
+	}
+	return analysisDocument{newDocumentHeader("analysis"), location, period.String(), analyzeData(readings), readings}
+}
+
+// table summarises the analysis in a single row; the readings are only in
+// the JSON and YAML forms.
+func (d analysisDocument) table() ([]string, [][]string) {
+	a := d.Analysis
+	row := []string{d.Location.Name, d.Period, strconv.Itoa(a.DataPoints), a.TimePeriod,
+		formatFloat(a.AverageTemp), formatFloat(a.MaxTemp), formatFloat(a.MinTemp), formatFloat(a.TempRange),
+		a.Trend, a.Recommendation}
+	if aq := a.AirQuality; aq != nil {
+		row = append(row, strconv.Itoa(aq.AverageUSEPA), strconv.Itoa(aq.MaxUSEPA), strconv.Itoa(aq.MaxUKDEFRA),
+			aq.HealthAdvice)
+	} else {
+		row = append(row, make([]string, 4)...)
+	}
+	derived := a.Derived
+	row = append(row, formatOptional(derived.AverageDewPointC), formatOptional(derived.MaxHeatIndexC),
+		formatFloat(derived.MinWindChillC), formatOptional(derived.MaxHumidex), formatOptional(derived.MaxWetBulbC),
+		formatOptional(derived.AverageAbsoluteHumidityGm3))
+	return []string{"location", "period", "data_points", "time_period", "average_temp_c", "max_temp_c",
+			"min_temp_c", "temp_range_c", "trend", "recommendation",
+			"average_us_epa_aqi", "max_us_epa_aqi", "max_uk_defra_daqi", "health_advice",
+			"average_dewpoint_c", "max_heat_index_c", "min_wind_chill_c", "max_humidex", "max_wetbulb_c",
+			"average_absolute_humidity_gm3"},
+		[][]string{row}
+}
+
+type readingsDocument struct {
+	documentHeader
+	Location Location      `json:"location"`
+	Period   string        `json:"period"`
+	Readings []WeatherData `json:"readings"`
+}
+
+func newReadingsDocument(location Location, period time.Duration, readings []WeatherData) readingsDocument {
+	if readings == nil {
+		readings = []WeatherData{}
+	}
+	return readingsDocument{newDocumentHeader("readings"), location, period.String(), readings}
+}
+
+// table lists one row per stored reading, with the same columns as a
+// current document.
+func (d readingsDocument) table() ([]string, [][]string) {
+	header, _ := currentDocument{}.table()
+	var rows [][]string
+	for i := range d.Readings {
+		_, row := newCurrentDocument(&d.Readings[i], nil).table()
+		rows = append(rows, row...)
+	}
+	return header, rows
+}
+
+type alertsDocument struct {
+	documentHeader
+	Events []AlertEvent `json:"events"`
+}
+
+func newAlertsDocument(events []AlertEvent) alertsDocument {
+	if events == nil {
+		events = []AlertEvent{}
+	}
+	return alertsDocument{newDocumentHeader("alerts"), events}
+}
+
+// table lists one row per event.
+func (d alertsDocument) table() ([]string, [][]string) {
+	header := []string{"rule", "status", "severity", "location", "metric", "operator", "threshold",
+		"value", "at", "since", "message"}
+	var rows [][]string
+	for _, e := range d.Events {
+		rows = append(rows, []string{e.Rule, e.Status, e.Severity, e.Location.Name, e.Metric, e.Operator,
+			formatFloat(e.Threshold), formatFloat(e.Value), formatTime(e.At), formatTime(e.Since), e.Message})
+	}
+	return header, rows
+}
+
+type deliveriesDocument struct {
+	documentHeader
+	Deliveries []Delivery `json:"deliveries"`
+}
+
+func newDeliveriesDocument(deliveries []Delivery) deliveriesDocument {
+	if deliveries == nil {
+		deliveries = []Delivery{}
+	}
+	return deliveriesDocument{newDocumentHeader("deliveries"), deliveries}
+}
+
+// table lists one row per delivery.
+func (d deliveriesDocument) table() ([]string, [][]string) {
+	header := []string{"time", "sink", "rule", "location", "status", "attempts", "delivered", "error"}
+	var rows [][]string
+	for _, delivery := range d.Deliveries {
+		rows = append(rows, []string{formatTime(delivery.Time), delivery.Sink, delivery.Rule, delivery.Location,
+			delivery.Status, strconv.Itoa(delivery.Attempts), strconv.FormatBool(delivery.Delivered), delivery.Error})
+	}
+	return header, rows
+}
+
+// writeDocument writes doc to w in a structured format.
+func writeDocument(w io.Writer, format string, doc document) error {
+	switch format {
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(doc)
+	case outputYAML:
+		return writeYAML(w, doc)
+	case outputCSV:
+		header, rows := doc.table()
+		writer := csv.NewWriter(w)
+		writer.Write(header)
+		writer.WriteAll(rows)
+		return writer.Error()
+	case outputTable:
+		header, rows := doc.table()
+		writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, strings.ToUpper(strings.Join(header, "\t")))
+		for _, row := range rows {
+			fmt.Fprintln(writer, strings.Join(row, "\t"))
+		}
+		return writer.Flush()
+	default:
+		return usageError("output format %q has no structured form", format)
+	}
+}
+
+// writeYAML converts the JSON form of doc, so both formats share one schema
+// and key order.
+func writeYAML(w io.Writer, doc document) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	clearYAMLStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// clearYAMLStyle switches nodes parsed from JSON to block style with plain
+// scalars. The encoder still quotes strings that would read as another type,
+// but only by YAML 1.2 rules, so YAML 1.1 booleans such as "yes" (a possible
+// condition) keep their quotes for older parsers.
+func clearYAMLStyle(node *yaml.Node) {
+	if node.Kind != yaml.ScalarNode || node.Tag != "!!str" || !yaml11Bools[strings.ToLower(node.Value)] {
+		node.Style = 0
+	}
+	for _, child := range node.Content {
+		clearYAMLStyle(child)
+	}
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// formatOptional formats a value that may be missing as an empty cell.
+func formatOptional(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return formatFloat(*value)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDocumentsDeclareMetricUnits(t *testing.T) {
+	weather := &WeatherData{Location: Location{Name: "London"}, TempC: 11, WindKph: 15.1, Humidity: 76}
+
+	for _, format := range []string{outputJSON, outputYAML} {
+		var buf bytes.Buffer
+		if err := writeDocument(&buf, format, newCurrentDocument(weather, nil)); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{"°C", "km/h", "temp_c"} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s document lacks %q:\n%s", format, want, buf.String())
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeDocument(&buf, outputJSON, newCurrentDocument(weather, nil)); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Units   map[string]string `json:"units"`
+		Current struct {
+			TempC float64 `json:"temp_c"`
+		} `json:"current"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Units["_c"] != "°C" || doc.Units["humidity"] != "%" {
+		t.Errorf("units = %v", doc.Units)
+	}
+	if doc.Current.TempC != 11 {
+		t.Errorf("temp_c = %v, want the stored metric value", doc.Current.TempC)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	providerWeatherAPI     = "weatherapi"
+	providerOpenWeatherMap = "openweathermap"
+	providerOpenMeteo      = "open-meteo"
+
+	// defaultProvider is used when an API key is configured but no provider
+	// is named; without a key the keyless Open-Meteo provider is used.
+	defaultProvider = providerWeatherAPI
+)
+
+// providerNames lists every supported provider.
+var providerNames = []string{providerWeatherAPI, providerOpenWeatherMap, providerOpenMeteo}
+
+// errNotSupported is returned when a provider has no equivalent endpoint.
+var errNotSupported = errors.New("not supported by this provider")
+
+// errNoAPIKey is returned when a provider needs a key and none is configured.
+var errNoAPIKey = errors.New("no API key configured")
+
+// WeatherProvider fetches weather data from a single upstream service and
+// normalizes it into the canonical model.
+type WeatherProvider interface {
+	Name() string
+	CurrentWeather(ctx context.Context, location string) (*WeatherData, error)
+	Forecast(ctx context.Context, location string, days int) (*Forecast, error)
+	History(ctx context.Context, location string, date time.Time) (*Forecast, error)
+
+	// Alerts returns the official warnings in force for location.
+	// Forecasts include them as well, where the provider has them.
+	Alerts(ctx context.Context, location string) ([]WeatherAlert, error)
+
+	// AirQuality returns the current pollutant concentrations at location.
+	// Current conditions include them as well, where the provider has them
+	// at no extra cost.
+	AirQuality(ctx context.Context, location string) (*AirQuality, error)
+}
+
+// newProvider returns the provider selected in the config.
+func newProvider(config Config) (WeatherProvider, error) {
+	name := strings.ToLower(config.Provider)
+	if name == "" {
+		name = defaultProvider
+		if apiKey, err := getAPIKey(config, name); err != nil {
+			return nil, err
+		} else if apiKey == "" {
+			name = providerOpenMeteo
+		}
+	}
+
+	client, err := newConfiguredClient(config, name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case providerWeatherAPI, providerOpenWeatherMap:
+		apiKey, err := getAPIKey(config, name)
+		if err != nil {
+			return nil, err
+		}
+		if apiKey == "" && !config.Offline && config.ReplayDir == "" {
+			return nil, fmt.Errorf("%w for %s; add it to the credentials file, set WEATHER_API_KEY or use provider %q", errNoAPIKey, name, providerOpenMeteo)
+		}
+		if name == providerWeatherAPI {
+			return newWeatherAPIProvider(client, apiKey), nil
+		}
+		return newOpenWeatherMapProvider(client, apiKey), nil
+	case providerOpenMeteo:
+		return newOpenMeteoProvider(client), nil
+	default:
+		return nil, configError(fmt.Errorf("unknown weather provider %q", config.Provider))
+	}
+}
+
+// newConfiguredClient builds the HTTP client for a provider. Replayed
+// responses cost nothing, so replay mode skips the rate limiter, quota and
+// cache; record mode skips the cache so every response reaches the fixtures.
+func newConfiguredClient(config Config, provider string) (*apiClient, error) {
+	client := newAPIClient(provider)
+	client.maxAttempts = config.MaxRetries + 1
+
+	if config.ReplayDir != "" {
+		transport, err := newReplayTransport(config.ReplayDir)
+		if err != nil {
+			return nil, err
+		}
+		client.http.Transport = transport
+		client.maxAttempts = 1
+		return client, nil
+	}
+
+	quota := quotaFor(config, provider)
+	client.limiter = limiterFor(provider, quota)
+
+	tracker, err := quotaTrackerFor(databasePath(config), provider, quota)
+	if err != nil {
+		return nil, fmt.Errorf("could not open quota tracker: %v", err)
+	}
+	client.quota = tracker
+
+	if config.RecordDir != "" {
+		transport, err := newRecordingTransport(config.RecordDir, nil)
+		if err != nil {
+			return nil, err
+		}
+		client.http.Transport = transport
+		return client, nil
+	}
+
+	cache, err := configuredCache(config)
+	if err != nil {
+		return nil, err
+	}
+	client.cache = cache
+	return client, nil
+}
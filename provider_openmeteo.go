@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	openMeteoForecastURL   = "https://api.open-meteo.com/v1"
+	openMeteoArchiveURL    = "https://archive-api.open-meteo.com/v1"
+	openMeteoGeocodingURL  = "https://geocoding-api.open-meteo.com/v1"
+	openMeteoAirQualityURL = "https://air-quality-api.open-meteo.com/v1"
+
+	openMeteoMaxDays = 16
+
+	openMeteoCurrentFields = "temperature_2m,relative_humidity_2m,apparent_temperature,precipitation,weather_code,pressure_msl,wind_speed_10m,wind_direction_10m"
+	openMeteoHourlyFields  = "temperature_2m,relative_humidity_2m,apparent_temperature,precipitation,precipitation_probability,weather_code,wind_speed_10m"
+	openMeteoDailyFields   = "temperature_2m_max,temperature_2m_min,temperature_2m_mean,weather_code"
+	openMeteoAirFields     = "pm2_5,pm10,ozone,nitrogen_dioxide,sulphur_dioxide,carbon_monoxide"
+
+	// The archive has no precipitation probability for past hours.
+	openMeteoArchiveHourlyFields = "temperature_2m,relative_humidity_2m,apparent_temperature,precipitation,weather_code,wind_speed_10m"
+)
+
+// openMeteoProvider talks to Open-Meteo, which needs no API key. Locations
+// are resolved through its geocoding API since the forecast endpoints only
+// accept coordinates.
+type openMeteoProvider struct {
+	client        *apiClient
+	forecastURL   string
+	archiveURL    string
+	geocodingURL  string
+	airQualityURL string
+}
+
+func newOpenMeteoProvider(client *apiClient) *openMeteoProvider {
+	return &openMeteoProvider{
+		client:        client,
+		forecastURL:   openMeteoForecastURL,
+		archiveURL:    openMeteoArchiveURL,
+		geocodingURL:  openMeteoGeocodingURL,
+		airQualityURL: openMeteoAirQualityURL,
+	}
+}
+
+// openMeteoGeocodingResponse mirrors the JSON returned by the search endpoint.
+type openMeteoGeocodingResponse struct {
+	Results []struct {
+		Name        string  `json:"name"`
+		Admin1      string  `json:"admin1"`
+		Country     string  `json:"country"`
+		CountryCode string  `json:"country_code"`
+		Lat         float64 `json:"latitude"`
+		Lon         float64 `json:"longitude"`
+		Timezone    string  `json:"timezone"`
+	} `json:"results"`
+}
+
+// openMeteoResponse mirrors the JSON returned by the forecast and archive
+// endpoints when requested with timeformat=unixtime.
+type openMeteoResponse struct {
+	Timezone string `json:"timezone"`
+	Current  struct {
+		Time                int64   `json:"time"`
+		Temperature2m       float64 `json:"temperature_2m"`
+		RelativeHumidity2m  float64 `json:"relative_humidity_2m"`
+		ApparentTemperature float64 `json:"apparent_temperature"`
+		Precipitation       float64 `json:"precipitation"`
+		WeatherCode         int     `json:"weather_code"`
+		PressureMsl         float64 `json:"pressure_msl"`
+		WindSpeed10m        float64 `json:"wind_speed_10m"`
+		WindDirection10m    float64 `json:"wind_direction_10m"`
+	} `json:"current"`
+	Hourly struct {
+		Time                []int64   `json:"time"`
+		Temperature2m       []float64 `json:"temperature_2m"`
+		RelativeHumidity2m  []float64 `json:"relative_humidity_2m"`
+		ApparentTemperature []float64 `json:"apparent_temperature"`
+		Precipitation       []float64 `json:"precipitation"`
+		PrecipProbability   []int     `json:"precipitation_probability"`
+		WeatherCode         []int     `json:"weather_code"`
+		WindSpeed10m        []float64 `json:"wind_speed_10m"`
+	} `json:"hourly"`
+	Daily struct {
+		Time              []int64   `json:"time"`
+		Temperature2mMax  []float64 `json:"temperature_2m_max"`
+		Temperature2mMin  []float64 `json:"temperature_2m_min"`
+		Temperature2mMean []float64 `json:"temperature_2m_mean"`
+		WeatherCode       []int     `json:"weather_code"`
+	} `json:"daily"`
+}
+
+func (p *openMeteoProvider) Name() string {
+	return providerOpenMeteo
+}
+
+func (p *openMeteoProvider) CurrentWeather(ctx context.Context, location string) (*WeatherData, error) {
+	forecast, err := p.Forecast(ctx, location, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &forecast.Current, nil
+}
+
+func (p *openMeteoProvider) Forecast(ctx context.Context, location string, days int) (*Forecast, error) {
+	loc, err := p.geocode(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	if days > openMeteoMaxDays {
+		days = openMeteoMaxDays
+	}
+	params := p.params(loc)
+	params.Set("current", openMeteoCurrentFields)
+	params.Set("forecast_days", strconv.Itoa(days))
+
+	var resp openMeteoResponse
+	if err := p.client.getJSON(ctx, p.forecastURL+"/forecast?"+params.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	return resp.forecast(loc), nil
+}
+
+func (p *openMeteoProvider) History(ctx context.Context, location string, date time.Time) (*Forecast, error) {
+	loc, err := p.geocode(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	day := date.Format("2006-01-02")
+	params := p.params(loc)
+	params.Set("hourly", openMeteoArchiveHourlyFields)
+	params.Set("start_date", day)
+	params.Set("end_date", day)
+
+	var resp openMeteoResponse
+	if err := p.client.getJSON(ctx, p.archiveURL+"/archive?"+params.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	return resp.forecast(loc), nil
+}
+
+// openMeteoAirQualityResponse mirrors the JSON returned by the air quality
+// endpoint. Concentrations are in µg/m³ and null where the model has none.
+type openMeteoAirQualityResponse struct {
+	Current struct {
+		PM2_5           *float64 `json:"pm2_5"`
+		PM10            *float64 `json:"pm10"`
+		Ozone           *float64 `json:"ozone"`
+		NitrogenDioxide *float64 `json:"nitrogen_dioxide"`
+		SulphurDioxide  *float64 `json:"sulphur_dioxide"`
+		CarbonMonoxide  *float64 `json:"carbon_monoxide"`
+	} `json:"current"`
+}
+
+// AirQuality uses the separate air quality API, which costs a request of
+// its own.
+func (p *openMeteoProvider) AirQuality(ctx context.Context, location string) (*AirQuality, error) {
+	loc, err := p.geocode(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("latitude", strconv.FormatFloat(loc.Lat, 'f', -1, 64))
+	params.Set("longitude", strconv.FormatFloat(loc.Lon, 'f', -1, 64))
+	params.Set("current", openMeteoAirFields)
+
+	var resp openMeteoAirQualityResponse
+	if err := p.client.getJSON(ctx, p.airQualityURL+"/air-quality?"+params.Encode(), &resp); err != nil {
+		return nil, err
+	}
+
+	c := resp.Current
+	if c.PM2_5 == nil && c.PM10 == nil {
+		return nil, nil
+	}
+	value := func(v *float64) float64 {
+		if v == nil {
+			return 0
+		}
+		return *v
+	}
+	return &AirQuality{
+		PM25Ug: value(c.PM2_5),
+		PM10Ug: value(c.PM10),
+		O3Ug:   value(c.Ozone),
+		NO2Ug:  value(c.NitrogenDioxide),
+		SO2Ug:  value(c.SulphurDioxide),
+		COUg:   value(c.CarbonMonoxide),
+	}, nil
+}
+
+// Alerts is not supported: Open-Meteo publishes no warnings.
+func (p *openMeteoProvider) Alerts(ctx context.Context, location string) ([]WeatherAlert, error) {
+	return nil, fmt.Errorf("alerts for %s: %w", p.Name(), errNotSupported)
+}
+
+func (p *openMeteoProvider) params(loc Location) url.Values {
+	params := url.Values{}
+	params.Set("latitude", strconv.FormatFloat(loc.Lat, 'f', -1, 64))
+	params.Set("longitude", strconv.FormatFloat(loc.Lon, 'f', -1, 64))
+	params.Set("hourly", openMeteoHourlyFields)
+	params.Set("daily", openMeteoDailyFields)
+	params.Set("timezone", "auto")
+	params.Set("timeformat", "unixtime")
+	return params
+}
+
+// geocode resolves a place name to coordinates. A "lat,lon" pair is used
+// as-is.
+func (p *openMeteoProvider) geocode(ctx context.Context, location string) (Location, error) {
+	if lat, lon, ok := parseCoordinates(location); ok {
+		return Location{Name: location, Lat: lat, Lon: lon}, nil
+	}
+
+	params := url.Values{}
+	params.Set("name", location)
+	params.Set("count", "1")
+
+	var resp openMeteoGeocodingResponse
+	if err := p.client.getJSON(ctx, p.geocodingURL+"/search?"+params.Encode(), &resp); err != nil {
+		return Location{}, err
+	}
+	if len(resp.Results) == 0 {
+		return Location{}, fmt.Errorf("%w: %q", errLocationNotFound, location)
+	}
+
+	result := resp.Results[0]
+	return Location{
+		Name:     result.Name,
+		Region:   result.Admin1,
+		Country:  result.Country,
+		Lat:      result.Lat,
+		Lon:      result.Lon,
+		Timezone: result.Timezone,
+	}, nil
+}
+
+func (r *openMeteoResponse) forecast(loc Location) *Forecast {
+	if loc.Timezone == "" {
+		loc.Timezone = r.Timezone
+	}
+
+	timestamp := time.Now()
+	if r.Current.Time > 0 {
+		timestamp = time.Unix(r.Current.Time, 0)
+	}
+
+	forecast := &Forecast{
+		Location: loc,
+		Current: WeatherData{
+			Location:   loc,
+			Timestamp:  timestamp,
+			TempC:      r.Current.Temperature2m,
+			FeelsLikeC: r.Current.ApparentTemperature,
+			Humidity:   int(r.Current.RelativeHumidity2m),
+			WindKph:    r.Current.WindSpeed10m,
+			WindDegree: int(r.Current.WindDirection10m),
+			PressureMb: r.Current.PressureMsl,
+			PrecipMm:   r.Current.Precipitation,
+			Condition:  weatherCodeText(r.Current.WeatherCode),
+		},
+	}
+
+	zone, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		zone = time.UTC
+	}
+
+	daily := r.Daily
+	for i, epoch := range daily.Time {
+		local := time.Unix(epoch, 0).In(zone)
+		forecast.Days = append(forecast.Days, ForecastDay{
+			Date:      time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC),
+			MaxTempC:  valueAt(daily.Temperature2mMax, i),
+			MinTempC:  valueAt(daily.Temperature2mMin, i),
+			AvgTempC:  valueAt(daily.Temperature2mMean, i),
+			Condition: weatherCodeText(valueAt(daily.WeatherCode, i)),
+		})
+	}
+
+	hourly := r.Hourly
+	for i, epoch := range hourly.Time {
+		hour := time.Unix(epoch, 0)
+		local := hour.In(zone)
+		date := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+
+		for d := range forecast.Days {
+			if !forecast.Days[d].Date.Equal(date) {
+				continue
+			}
+			forecast.Days[d].Hours = append(forecast.Days[d].Hours, ForecastHour{
+				Time:         hour,
+				TempC:        valueAt(hourly.Temperature2m, i),
+				FeelsLikeC:   valueAt(hourly.ApparentTemperature, i),
+				Humidity:     int(valueAt(hourly.RelativeHumidity2m, i)),
+				WindKph:      valueAt(hourly.WindSpeed10m, i),
+				PrecipMm:     valueAt(hourly.Precipitation, i),
+				ChanceOfRain: valueAt(hourly.PrecipProbability, i),
+				Condition:    weatherCodeText(valueAt(hourly.WeatherCode, i)),
+			})
+			break
+		}
+	}
+	return forecast
+}
+
+// valueAt guards against Open-Meteo returning shorter arrays than requested.
+func valueAt[T any](values []T, i int) T {
+	var zero T
+	if i < len(values) {
+		return values[i]
+	}
+	return zero
+}
+
+// parseCoordinates recognises "lat,lon" location strings.
+func parseCoordinates(location string) (float64, float64, bool) {
+	parts := strings.Split(location, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// weatherCodeText describes a WMO weather interpretation code.
+func weatherCodeText(code int) string {
+	switch code {
+	case 0:
+		return "Clear sky"
+	case 1:
+		return "Mainly clear"
+	case 2:
+		return "Partly cloudy"
+	case 3:
+		return "Overcast"
+	case 45, 48:
+		return "Fog"
+	case 51, 53, 55:
+		return "Drizzle"
+	case 56, 57:
+		return "Freezing drizzle"
+	case 61, 63, 65:
+		return "Rain"
+	case 66, 67:
+		return "Freezing rain"
+	case 71, 73, 75:
+		return "Snow"
+	case 77:
+		return "Snow grains"
+	case 80, 81, 82:
+		return "Rain showers"
+	case 85, 86:
+		return "Snow showers"
+	case 95:
+		return "Thunderstorm"
+	case 96, 99:
+		return "Thunderstorm with hail"
+	default:
+		return "Unknown"
+	}
+}
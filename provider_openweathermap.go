@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	openWeatherMapBaseURL = "https://api.openweathermap.org/data/2.5"
+
+	// The free forecast endpoint returns 3-hourly steps for five days.
+	openWeatherMapMaxDays = 5
+)
+
+// openWeatherMapProvider talks to OpenWeatherMap.
+type openWeatherMapProvider struct {
+	client  *apiClient
+	apiKey  string
+	baseURL string
+}
+
+func newOpenWeatherMapProvider(client *apiClient, apiKey string) *openWeatherMapProvider {
+	return &openWeatherMapProvider{client: client, apiKey: apiKey, baseURL: openWeatherMapBaseURL}
+}
+
+type openWeatherMapMain struct {
+	Temp      float64 `json:"temp"`
+	FeelsLike float64 `json:"feels_like"`
+	Humidity  int     `json:"humidity"`
+	Pressure  float64 `json:"pressure"` // hPa
+}
+
+type openWeatherMapCondition struct {
+	Description string `json:"description"`
+}
+
+type openWeatherMapWind struct {
+	Speed float64 `json:"speed"` // m/s with units=metric
+	Deg   int     `json:"deg"`
+}
+
+type openWeatherMapRain struct {
+	OneHour   float64 `json:"1h"`
+	ThreeHour float64 `json:"3h"`
+}
+
+// WeatherResponse mirrors the JSON returned by the current weather endpoint.
+type WeatherResponse struct {
+	Name  string `json:"name"`
+	Dt    int64  `json:"dt"`
+	Coord struct {
+		Lat float64 `json:"lat"`
+		Lon float64 `json:"lon"`
+	} `json:"coord"`
+	Sys struct {
+		Country string `json:"country"`
+	} `json:"sys"`
+	Main    openWeatherMapMain        `json:"main"`
+	Weather []openWeatherMapCondition `json:"weather"`
+	Wind    openWeatherMapWind        `json:"wind"`
+	Rain    openWeatherMapRain        `json:"rain"`
+}
+
+// openWeatherMapForecastResponse mirrors the JSON returned by the 5 day
+// forecast endpoint.
+type openWeatherMapForecastResponse struct {
+	List []struct {
+		Dt      int64                     `json:"dt"`
+		Main    openWeatherMapMain        `json:"main"`
+		Weather []openWeatherMapCondition `json:"weather"`
+		Wind    openWeatherMapWind        `json:"wind"`
+		Rain    openWeatherMapRain        `json:"rain"`
+		Pop     float64                   `json:"pop"` // probability of precipitation, 0-1
+	} `json:"list"`
+	City struct {
+		Name    string `json:"name"`
+		Country string `json:"country"`
+		Coord   struct {
+			Lat float64 `json:"lat"`
+			Lon float64 `json:"lon"`
+		} `json:"coord"`
+		Timezone int `json:"timezone"` // offset from UTC in seconds
+	} `json:"city"`
+}
+
+// openWeatherMapAirPollutionResponse mirrors the JSON returned by the air
+// pollution endpoint. Components are in µg/m³.
+type openWeatherMapAirPollutionResponse struct {
+	List []struct {
+		Components struct {
+			CO    float64 `json:"co"`
+			NO2   float64 `json:"no2"`
+			O3    float64 `json:"o3"`
+			SO2   float64 `json:"so2"`
+			PM2_5 float64 `json:"pm2_5"`
+			PM10  float64 `json:"pm10"`
+		} `json:"components"`
+	} `json:"list"`
+}
+
+func (p *openWeatherMapProvider) Name() string {
+	return providerOpenWeatherMap
+}
+
+func (p *openWeatherMapProvider) CurrentWeather(ctx context.Context, location string) (*WeatherData, error) {
+	var resp WeatherResponse
+	if err := p.client.getJSON(ctx, p.endpoint("weather", location, nil), &resp); err != nil {
+		return nil, err
+	}
+
+	current := resp.weatherData()
+	return &current, nil
+}
+
+func (p *openWeatherMapProvider) Forecast(ctx context.Context, location string, days int) (*Forecast, error) {
+	current, err := p.CurrentWeather(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	if days > openWeatherMapMaxDays {
+		days = openWeatherMapMaxDays
+	}
+	params := url.Values{}
+	params.Set("cnt", strconv.Itoa(days*8))
+
+	var resp openWeatherMapForecastResponse
+	if err := p.client.getJSON(ctx, p.endpoint("forecast", location, params), &resp); err != nil {
+		return nil, err
+	}
+
+	return &Forecast{
+		Location: current.Location,
+		Current:  *current,
+		Days:     resp.days(),
+	}, nil
+}
+
+func (p *openWeatherMapProvider) History(ctx context.Context, location string, date time.Time) (*Forecast, error) {
+	return nil, fmt.Errorf("history for %s: %w", p.Name(), errNotSupported)
+}
+
+// AirQuality needs coordinates; for a place name the current weather is
+// fetched first to find them.
+func (p *openWeatherMapProvider) AirQuality(ctx context.Context, location string) (*AirQuality, error) {
+	if _, _, ok := parseCoordinates(location); !ok {
+		current, err := p.CurrentWeather(ctx, location)
+		if err != nil {
+			return nil, err
+		}
+		location = formatCoordinates(current.Location.Lat, current.Location.Lon)
+	}
+
+	var resp openWeatherMapAirPollutionResponse
+	if err := p.client.getJSON(ctx, p.endpoint("air_pollution", location, nil), &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.List) == 0 {
+		return nil, nil
+	}
+	c := resp.List[0].Components
+	return &AirQuality{PM25Ug: c.PM2_5, PM10Ug: c.PM10, O3Ug: c.O3, NO2Ug: c.NO2, SO2Ug: c.SO2, COUg: c.CO}, nil
+}
+
+// Alerts is not supported: warnings are only in the paid One Call API.
+func (p *openWeatherMapProvider) Alerts(ctx context.Context, location string) ([]WeatherAlert, error) {
+	return nil, fmt.Errorf("alerts for %s: %w", p.Name(), errNotSupported)
+}
+
+func (p *openWeatherMapProvider) endpoint(path, location string, params url.Values) string {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("appid", p.apiKey)
+	if lat, lon, ok := parseCoordinates(location); ok {
+		params.Set("lat", strconv.FormatFloat(lat, 'f', -1, 64))
+		params.Set("lon", strconv.FormatFloat(lon, 'f', -1, 64))
+	} else {
+		params.Set("q", location)
+	}
+	params.Set("units", "metric")
+	return fmt.Sprintf("%s/%s?%s", p.baseURL, path, params.Encode())
+}
+
+func (r *WeatherResponse) weatherData() WeatherData {
+	timestamp := time.Now()
+	if r.Dt > 0 {
+		timestamp = time.Unix(r.Dt, 0)
+	}
+
+	return WeatherData{
+		Location: Location{
+			Name:    r.Name,
+			Country: r.Sys.Country,
+			Lat:     r.Coord.Lat,
+			Lon:     r.Coord.Lon,
+		},
+		Timestamp:  timestamp,
+		TempC:      r.Main.Temp,
+		FeelsLikeC: r.Main.FeelsLike,
+		Humidity:   r.Main.Humidity,
+		WindKph:    msToKph(r.Wind.Speed),
+		WindDegree: r.Wind.Deg,
+		PressureMb: r.Main.Pressure,
+		PrecipMm:   r.Rain.OneHour,
+		Condition:  firstCondition(r.Weather),
+	}
+}
+
+// days groups the 3-hourly forecast steps into local calendar days.
+func (r *openWeatherMapForecastResponse) days() []ForecastDay {
+	zone := time.FixedZone("", r.City.Timezone)
+
+	var days []ForecastDay
+	var count int
+	for _, step := range r.List {
+		local := time.Unix(step.Dt, 0).In(zone)
+		date := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+
+		if len(days) == 0 || !days[len(days)-1].Date.Equal(date) {
+			if len(days) > 0 {
+				days[len(days)-1].AvgTempC /= float64(count)
+			}
+			days = append(days, ForecastDay{
+				Date:      date,
+				MaxTempC:  step.Main.Temp,
+				MinTempC:  step.Main.Temp,
+				Condition: firstCondition(step.Weather),
+			})
+			count = 0
+		}
+
+		day := &days[len(days)-1]
+		day.Hours = append(day.Hours, ForecastHour{
+			Time:         local,
+			TempC:        step.Main.Temp,
+			FeelsLikeC:   step.Main.FeelsLike,
+			Humidity:     step.Main.Humidity,
+			WindKph:      msToKph(step.Wind.Speed),
+			PrecipMm:     step.Rain.ThreeHour,
+			ChanceOfRain: int(math.Round(step.Pop * 100)),
+			Condition:    firstCondition(step.Weather),
+		})
+		day.MaxTempC = max(day.MaxTempC, step.Main.Temp)
+		day.MinTempC = min(day.MinTempC, step.Main.Temp)
+		day.AvgTempC += step.Main.Temp
+		count++
+	}
+	if len(days) > 0 {
+		days[len(days)-1].AvgTempC /= float64(count)
+	}
+	return days
+}
+
+func msToKph(speed float64) float64 {
+	return speed * 3.6
+}
+
+func firstCondition(conditions []openWeatherMapCondition) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return conditions[0].Description
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubServer answers with the recorded responses in testdata/<provider>,
+// picked by the last element of the request path, and keeps every request
+// for inspection.
+type stubServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []*url.URL
+}
+
+func newStubServer(t *testing.T, provider string) *stubServer {
+	t.Helper()
+	stub := &stubServer{}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stub.mu.Lock()
+		stub.requests = append(stub.requests, r.URL)
+		stub.mu.Unlock()
+
+		name := path.Base(r.URL.Path)
+		if filepath.Ext(name) == "" {
+			name += ".json"
+		}
+		body, err := os.ReadFile(filepath.Join("testdata", provider, name))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+// lastRequest returns the most recent request whose path ends in name.
+func (s *stubServer) lastRequest(t *testing.T, name string) *url.URL {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if path.Base(s.requests[i].Path) == name {
+			return s.requests[i]
+		}
+	}
+	t.Fatalf("no request for %s", name)
+	return nil
+}
+
+// stubClient returns a client without retries, cache or quota.
+func stubClient(provider string) *apiClient {
+	client := newAPIClient(provider)
+	client.maxAttempts = 1
+	return client
+}
+
+var historyDate = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func TestWeatherAPIProvider(t *testing.T) {
+	stub := newStubServer(t, providerWeatherAPI)
+	provider := newWeatherAPIProvider(stubClient(providerWeatherAPI), "secret")
+	provider.baseURL = stub.URL
+	ctx := context.Background()
+
+	t.Run("current", func(t *testing.T) {
+		current, err := provider.CurrentWeather(ctx, "London")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.Location.Name != "London" || current.Location.Timezone != "Europe/London" {
+			t.Errorf("location = %+v", current.Location)
+		}
+		if current.TempC != 11 || current.FeelsLikeC != 9.2 || current.Humidity != 76 || current.WindKph != 15.1 ||
+			current.WindDegree != 230 || current.PressureMb != 1004 || current.PrecipMm != 0.1 {
+			t.Errorf("current = %+v", current)
+		}
+		if current.Condition != "Partly cloudy" {
+			t.Errorf("condition = %q", current.Condition)
+		}
+		if !current.Timestamp.Equal(time.Unix(1709294400, 0)) {
+			t.Errorf("timestamp = %v", current.Timestamp)
+		}
+		if current.AirQuality == nil || current.AirQuality.PM25Ug != 6.1 || current.AirQuality.COUg != 230.3 {
+			t.Errorf("air quality = %+v", current.AirQuality)
+		}
+
+		query := stub.lastRequest(t, "current.json").Query()
+		if query.Get("key") != "secret" || query.Get("q") != "London" || query.Get("aqi") != "yes" {
+			t.Errorf("query = %v", query)
+		}
+	})
+
+	t.Run("forecast", func(t *testing.T) {
+		forecast, err := provider.Forecast(ctx, "London", 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(forecast.Days) != 2 {
+			t.Fatalf("got %d days, want 2", len(forecast.Days))
+		}
+		day := forecast.Days[0]
+		if !day.Date.Equal(historyDate) || day.MaxTempC != 12.4 || day.MinTempC != 6.1 || day.AvgTempC != 9.3 {
+			t.Errorf("day = %+v", day)
+		}
+		if len(day.Hours) != 2 || day.Hours[0].ChanceOfRain != 64 || day.Hours[1].TempC != 12.4 {
+			t.Errorf("hours = %+v", day.Hours)
+		}
+
+		if len(forecast.Alerts) != 1 {
+			t.Fatalf("got %d alerts, want 1", len(forecast.Alerts))
+		}
+		alert := forecast.Alerts[0]
+		if alert.Severity != "Moderate" || len(alert.Areas) != 2 || alert.Areas[1] != "East of England" {
+			t.Errorf("alert = %+v", alert)
+		}
+		if alert.Description != "Strong winds may cause some disruption." {
+			t.Errorf("description = %q", alert.Description)
+		}
+		if !alert.Effective.Equal(time.Date(2024, 3, 1, 15, 0, 0, 0, time.UTC)) {
+			t.Errorf("effective = %v", alert.Effective)
+		}
+
+		query := stub.lastRequest(t, "forecast.json").Query()
+		if query.Get("days") != "2" || query.Get("alerts") != "yes" {
+			t.Errorf("query = %v", query)
+		}
+	})
+
+	t.Run("history", func(t *testing.T) {
+		history, err := provider.History(ctx, "London", historyDate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history.Days) != 1 || len(history.Days[0].Hours) != 2 {
+			t.Fatalf("days = %+v", history.Days)
+		}
+		if hour := history.Days[0].Hours[1]; hour.TempC != 6.3 || hour.PrecipMm != 0.2 || hour.Condition != "Light drizzle" {
+			t.Errorf("hour = %+v", hour)
+		}
+		if query := stub.lastRequest(t, "history.json").Query(); query.Get("dt") != "2024-03-01" {
+			t.Errorf("query = %v", query)
+		}
+	})
+}
+
+func TestOpenWeatherMapProvider(t *testing.T) {
+	stub := newStubServer(t, providerOpenWeatherMap)
+	provider := newOpenWeatherMapProvider(stubClient(providerOpenWeatherMap), "secret")
+	provider.baseURL = stub.URL
+	ctx := context.Background()
+
+	t.Run("current", func(t *testing.T) {
+		current, err := provider.CurrentWeather(ctx, "London")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.Location.Name != "London" || current.Location.Country != "GB" || current.Location.Lat != 51.5085 {
+			t.Errorf("location = %+v", current.Location)
+		}
+		// Wind arrives in m/s.
+		if current.TempC != 11 || current.Humidity != 76 || current.WindKph != msToKph(4.2) || current.PrecipMm != 0.12 {
+			t.Errorf("current = %+v", current)
+		}
+		if current.Condition != "broken clouds" {
+			t.Errorf("condition = %q", current.Condition)
+		}
+
+		query := stub.lastRequest(t, "weather").Query()
+		if query.Get("appid") != "secret" || query.Get("q") != "London" || query.Get("units") != "metric" {
+			t.Errorf("query = %v", query)
+		}
+	})
+
+	t.Run("forecast", func(t *testing.T) {
+		forecast, err := provider.Forecast(ctx, "London", 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(forecast.Days) != 2 {
+			t.Fatalf("got %d days, want 2", len(forecast.Days))
+		}
+		day := forecast.Days[0]
+		if !day.Date.Equal(historyDate) || day.MaxTempC != 12 || day.MinTempC != 9 || day.AvgTempC != 32.0/3 {
+			t.Errorf("day = %+v", day)
+		}
+		if len(day.Hours) != 3 || day.Hours[0].ChanceOfRain != 64 || day.Hours[0].PrecipMm != 0.6 {
+			t.Errorf("hours = %+v", day.Hours)
+		}
+		// Five days at most, in 3-hourly steps.
+		if query := stub.lastRequest(t, "forecast").Query(); query.Get("cnt") != "40" {
+			t.Errorf("query = %v", query)
+		}
+	})
+
+	t.Run("history", func(t *testing.T) {
+		if _, err := provider.History(ctx, "London", historyDate); !errors.Is(err, errNotSupported) {
+			t.Errorf("err = %v, want errNotSupported", err)
+		}
+	})
+}
+
+func TestOpenMeteoProvider(t *testing.T) {
+	stub := newStubServer(t, providerOpenMeteo)
+	provider := newOpenMeteoProvider(stubClient(providerOpenMeteo))
+	provider.forecastURL = stub.URL
+	provider.archiveURL = stub.URL
+	provider.geocodingURL = stub.URL
+	provider.airQualityURL = stub.URL
+	ctx := context.Background()
+
+	t.Run("current", func(t *testing.T) {
+		current, err := provider.CurrentWeather(ctx, "London")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.Location.Name != "London" || current.Location.Region != "England" || current.Location.Lat != 51.50853 {
+			t.Errorf("location = %+v", current.Location)
+		}
+		if current.TempC != 11 || current.FeelsLikeC != 8.9 || current.Humidity != 76 || current.PressureMb != 1004.2 ||
+			current.WindDegree != 230 {
+			t.Errorf("current = %+v", current)
+		}
+		if current.Condition != "Rain" {
+			t.Errorf("condition = %q", current.Condition)
+		}
+		if query := stub.lastRequest(t, "search").Query(); query.Get("name") != "London" {
+			t.Errorf("query = %v", query)
+		}
+	})
+
+	t.Run("forecast", func(t *testing.T) {
+		forecast, err := provider.Forecast(ctx, "51.5,-0.12", 30)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(forecast.Days) != 2 {
+			t.Fatalf("got %d days, want 2", len(forecast.Days))
+		}
+		day := forecast.Days[0]
+		if !day.Date.Equal(historyDate) || day.MaxTempC != 12.4 || day.Condition != "Rain" {
+			t.Errorf("day = %+v", day)
+		}
+		// Hours are filed under their local day.
+		if len(day.Hours) != 2 || len(forecast.Days[1].Hours) != 1 || forecast.Days[1].Hours[0].ChanceOfRain != 85 {
+			t.Errorf("hours = %+v, %+v", day.Hours, forecast.Days[1].Hours)
+		}
+
+		query := stub.lastRequest(t, "forecast").Query()
+		if query.Get("latitude") != "51.5" || query.Get("forecast_days") != "16" {
+			t.Errorf("query = %v", query)
+		}
+	})
+
+	t.Run("history", func(t *testing.T) {
+		history, err := provider.History(ctx, "London", historyDate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history.Days) != 1 || len(history.Days[0].Hours) != 2 {
+			t.Fatalf("days = %+v", history.Days)
+		}
+		if hour := history.Days[0].Hours[1]; hour.TempC != 6.3 || hour.Humidity != 87 || hour.Condition != "Drizzle" {
+			t.Errorf("hour = %+v", hour)
+		}
+
+		query := stub.lastRequest(t, "archive").Query()
+		if query.Get("start_date") != "2024-03-01" || query.Get("end_date") != "2024-03-01" {
+			t.Errorf("query = %v", query)
+		}
+		if hourly := query.Get("hourly"); hourly != openMeteoArchiveHourlyFields {
+			t.Errorf("hourly = %q, want the archive fields", hourly)
+		}
+	})
+}
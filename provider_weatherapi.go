@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const weatherAPIBaseURL = "https://api.weatherapi.com/v1"
+
+// weatherAPIProvider talks to WeatherAPI.com.
+type weatherAPIProvider struct {
+	client  *apiClient
+	apiKey  string
+	baseURL string
+}
+
+func newWeatherAPIProvider(client *apiClient, apiKey string) *weatherAPIProvider {
+	return &weatherAPIProvider{client: client, apiKey: apiKey, baseURL: weatherAPIBaseURL}
+}
+
+type weatherAPICondition struct {
+	Text string `json:"text"`
+}
+
+// weatherAPIResponse mirrors the JSON returned by the current, forecast,
+// history and alerts endpoints.
+type weatherAPIResponse struct {
+	Location struct {
+		Name    string  `json:"name"`
+		Region  string  `json:"region"`
+		Country string  `json:"country"`
+		Lat     float64 `json:"lat"`
+		Lon     float64 `json:"lon"`
+		TzID    string  `json:"tz_id"`
+	} `json:"location"`
+	Current struct {
+		LastUpdatedEpoch int64               `json:"last_updated_epoch"`
+		TempC            float64             `json:"temp_c"`
+		Condition        weatherAPICondition `json:"condition"`
+		Humidity         int                 `json:"humidity"`
+		WindKph          float64             `json:"wind_kph"`
+		WindDegree       int                 `json:"wind_degree"`
+		PressureMb       float64             `json:"pressure_mb"`
+		PrecipMm         float64             `json:"precip_mm"`
+		FeelsLikeC       float64             `json:"feelslike_c"`
+		AirQuality       *struct {
+			CO    float64 `json:"co"` // all in µg/m³
+			NO2   float64 `json:"no2"`
+			O3    float64 `json:"o3"`
+			SO2   float64 `json:"so2"`
+			PM2_5 float64 `json:"pm2_5"`
+			PM10  float64 `json:"pm10"`
+		} `json:"air_quality"`
+	} `json:"current"`
+	Forecast struct {
+		Forecastday []struct {
+			Date string `json:"date"`
+			Day  struct {
+				MaxTempC  float64             `json:"maxtemp_c"`
+				MinTempC  float64             `json:"mintemp_c"`
+				AvgTempC  float64             `json:"avgtemp_c"`
+				Condition weatherAPICondition `json:"condition"`
+			} `json:"day"`
+			Hour []struct {
+				TimeEpoch    int64               `json:"time_epoch"`
+				TempC        float64             `json:"temp_c"`
+				FeelsLikeC   float64             `json:"feelslike_c"`
+				Humidity     int                 `json:"humidity"`
+				WindKph      float64             `json:"wind_kph"`
+				PrecipMm     float64             `json:"precip_mm"`
+				ChanceOfRain int                 `json:"chance_of_rain"`
+				Condition    weatherAPICondition `json:"condition"`
+			} `json:"hour"`
+		} `json:"forecastday"`
+	} `json:"forecast"`
+	Alerts struct {
+		Alert []struct {
+			Headline    string `json:"headline"`
+			Severity    string `json:"severity"`
+			Urgency     string `json:"urgency"`
+			Areas       string `json:"areas"` // separated by semicolons
+			Certainty   string `json:"certainty"`
+			Event       string `json:"event"`
+			Effective   string `json:"effective"` // RFC 3339
+			Expires     string `json:"expires"`
+			Desc        string `json:"desc"`
+			Instruction string `json:"instruction"`
+		} `json:"alert"`
+	} `json:"alerts"`
+}
+
+func (p *weatherAPIProvider) Name() string {
+	return providerWeatherAPI
+}
+
+func (p *weatherAPIProvider) CurrentWeather(ctx context.Context, location string) (*WeatherData, error) {
+	params := url.Values{}
+	params.Set("aqi", "yes")
+
+	var resp weatherAPIResponse
+	if err := p.client.getJSON(ctx, p.endpoint("current.json", location, params), &resp); err != nil {
+		return nil, err
+	}
+
+	current := resp.currentWeather()
+	return &current, nil
+}
+
+func (p *weatherAPIProvider) Forecast(ctx context.Context, location string, days int) (*Forecast, error) {
+	params := url.Values{}
+	params.Set("days", strconv.Itoa(days))
+	params.Set("aqi", "yes")
+	params.Set("alerts", "yes")
+
+	var resp weatherAPIResponse
+	if err := p.client.getJSON(ctx, p.endpoint("forecast.json", location, params), &resp); err != nil {
+		return nil, err
+	}
+	return resp.forecast(), nil
+}
+
+func (p *weatherAPIProvider) History(ctx context.Context, location string, date time.Time) (*Forecast, error) {
+	params := url.Values{}
+	params.Set("dt", date.Format("2006-01-02"))
+
+	var resp weatherAPIResponse
+	if err := p.client.getJSON(ctx, p.endpoint("history.json", location, params), &resp); err != nil {
+		return nil, err
+	}
+	return resp.forecast(), nil
+}
+
+func (p *weatherAPIProvider) Alerts(ctx context.Context, location string) ([]WeatherAlert, error) {
+	var resp weatherAPIResponse
+	if err := p.client.getJSON(ctx, p.endpoint("alerts.json", location, nil), &resp); err != nil {
+		return nil, err
+	}
+	return resp.alerts(), nil
+}
+
+func (p *weatherAPIProvider) AirQuality(ctx context.Context, location string) (*AirQuality, error) {
+	current, err := p.CurrentWeather(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	return current.AirQuality, nil
+}
+
+func (p *weatherAPIProvider) endpoint(path, location string, params url.Values) string {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("key", p.apiKey)
+	params.Set("q", location)
+	return fmt.Sprintf("%s/%s?%s", p.baseURL, path, params.Encode())
+}
+
+func (r *weatherAPIResponse) location() Location {
+	return Location{
+		Name:     r.Location.Name,
+		Region:   r.Location.Region,
+		Country:  r.Location.Country,
+		Lat:      r.Location.Lat,
+		Lon:      r.Location.Lon,
+		Timezone: r.Location.TzID,
+	}
+}
+
+func (r *weatherAPIResponse) currentWeather() WeatherData {
+	timestamp := time.Now()
+	if r.Current.LastUpdatedEpoch > 0 {
+		timestamp = time.Unix(r.Current.LastUpdatedEpoch, 0)
+	}
+
+	var airQuality *AirQuality
+	if aq := r.Current.AirQuality; aq != nil {
+		airQuality = &AirQuality{PM25Ug: aq.PM2_5, PM10Ug: aq.PM10, O3Ug: aq.O3, NO2Ug: aq.NO2, SO2Ug: aq.SO2, COUg: aq.CO}
+	}
+
+	return WeatherData{
+		Location:   r.location(),
+		Timestamp:  timestamp,
+		TempC:      r.Current.TempC,
+		FeelsLikeC: r.Current.FeelsLikeC,
+		Humidity:   r.Current.Humidity,
+		WindKph:    r.Current.WindKph,
+		WindDegree: r.Current.WindDegree,
+		PressureMb: r.Current.PressureMb,
+		PrecipMm:   r.Current.PrecipMm,
+		Condition:  r.Current.Condition.Text,
+		AirQuality: airQuality,
+	}
+}
+
+func (r *weatherAPIResponse) forecast() *Forecast {
+	forecast := &Forecast{
+		Location: r.location(),
+		Current:  r.currentWeather(),
+	}
+
+	for _, day := range r.Forecast.Forecastday {
+		date, _ := time.Parse("2006-01-02", day.Date)
+		forecastDay := ForecastDay{
+			Date:      date,
+			MaxTempC:  day.Day.MaxTempC,
+			MinTempC:  day.Day.MinTempC,
+			AvgTempC:  day.Day.AvgTempC,
+			Condition: day.Day.Condition.Text,
+		}
+
+		for _, hour := range day.Hour {
+			forecastDay.Hours = append(forecastDay.Hours, ForecastHour{
+				Time:         time.Unix(hour.TimeEpoch, 0),
+				TempC:        hour.TempC,
+				FeelsLikeC:   hour.FeelsLikeC,
+				Humidity:     hour.Humidity,
+				WindKph:      hour.WindKph,
+				PrecipMm:     hour.PrecipMm,
+				ChanceOfRain: hour.ChanceOfRain,
+				Condition:    hour.Condition.Text,
+			})
+		}
+		forecast.Days = append(forecast.Days, forecastDay)
+	}
+	forecast.Alerts = r.alerts()
+	return forecast
+}
+
+func (r *weatherAPIResponse) alerts() []WeatherAlert {
+	var alerts []WeatherAlert
+	for _, alert := range r.Alerts.Alert {
+		var areas []string
+		for _, area := range strings.Split(alert.Areas, ";") {
+			if area = strings.TrimSpace(area); area != "" {
+				areas = append(areas, area)
+			}
+		}
+		// A time that does not parse is left zero: an unknown expiry keeps
+		// the warning in force rather than hiding it.
+		effective, _ := time.Parse(time.RFC3339, alert.Effective)
+		expires, _ := time.Parse(time.RFC3339, alert.Expires)
+
+		headline := alert.Headline
+		if headline == "" {
+			headline = alert.Event
+		}
+		alerts = append(alerts, WeatherAlert{
+			Event:       alert.Event,
+			Headline:    headline,
+			Severity:    normalizeSeverity(alert.Severity),
+			Urgency:     alert.Urgency,
+			Certainty:   alert.Certainty,
+			Areas:       areas,
+			Description: strings.TrimSpace(alert.Desc),
+			Instruction: strings.TrimSpace(alert.Instruction),
+			Effective:   effective,
+			Expires:     expires,
+		})
+	}
+	return alerts
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// errQuotaExceeded is returned instead of making a call that would go over
+// the configured daily or monthly limit.
+var errQuotaExceeded = errors.New("API quota exceeded")
+
+const defaultQuotaWarnPercent = 80
+
+// QuotaConfig limits how fast and how often a provider is called. Zero
+// values disable the corresponding limit.
+type QuotaConfig struct {
+	RequestsPerMinute float64 `json:"requests_per_minute,omitempty"`
+	Burst             int     `json:"burst,omitempty"`
+	DailyLimit        int     `json:"daily_limit,omitempty"`
+	MonthlyLimit      int     `json:"monthly_limit,omitempty"`
+
+	// WarnPercent is the share of a limit after which a warning is logged.
+	WarnPercent int `json:"warn_percent,omitempty"`
+}
+
+// defaultQuotas follow each provider's free tier.
+var defaultQuotas = map[string]QuotaConfig{
+	providerWeatherAPI:     {RequestsPerMinute: 600, Burst: 10, MonthlyLimit: 1000000},
+	providerOpenWeatherMap: {RequestsPerMinute: 60, Burst: 5, DailyLimit: 1000},
+	providerOpenMeteo:      {RequestsPerMinute: 600, Burst: 10, DailyLimit: 10000},
+	aviationWeather:        {RequestsPerMinute: 60, Burst: 5},
+}
+
+// quotaFor merges the configured limits for provider over its defaults.
+func quotaFor(config Config, provider string) QuotaConfig {
+	quota := defaultQuotas[provider]
+	if override, ok := config.Quotas[provider]; ok {
+		if override.RequestsPerMinute != 0 {
+			quota.RequestsPerMinute = override.RequestsPerMinute
+		}
+		if override.Burst != 0 {
+			quota.Burst = override.Burst
+		}
+		if override.DailyLimit != 0 {
+			quota.DailyLimit = override.DailyLimit
+		}
+		if override.MonthlyLimit != 0 {
+			quota.MonthlyLimit = override.MonthlyLimit
+		}
+		if override.WarnPercent != 0 {
+			quota.WarnPercent = override.WarnPercent
+		}
+	}
+	if quota.WarnPercent == 0 {
+		quota.WarnPercent = defaultQuotaWarnPercent
+	}
+	return quota
+}
+
+// tokenBucket is a client-side rate limiter: it holds up to capacity tokens
+// and refills at rate tokens per second.
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64
+	last     time.Time
+}
+
+func newTokenBucket(requestsPerMinute float64, burst int) *tokenBucket {
+	capacity := math.Max(float64(burst), 1)
+	return &tokenBucket{
+		capacity: capacity,
+		tokens:   capacity,
+		rate:     requestsPerMinute / 60,
+		last:     time.Now(),
+	}
+}
+
+// Wait blocks until a token is available or ctx is done.
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[string]*tokenBucket)
+)
+
+// limiterFor returns the process-wide rate limiter for a provider, so every
+// client talking to the same provider shares one budget.
+func limiterFor(provider string, quota QuotaConfig) *tokenBucket {
+	if quota.RequestsPerMinute <= 0 {
+		return nil
+	}
+
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	if limiter, ok := limiters[provider]; ok {
+		return limiter
+	}
+	limiter := newTokenBucket(quota.RequestsPerMinute, quota.Burst)
+	limiters[provider] = limiter
+	return limiter
+}
+
+const quotaSchema = `
+CREATE TABLE IF NOT EXISTS api_calls (
+	provider TEXT    NOT NULL,
+	period   TEXT    NOT NULL,
+	count    INTEGER NOT NULL,
+	PRIMARY KEY (provider, period)
+);
+`
+
+// quotaTracker persists how many calls were made to a provider per UTC day
+// and month, so limits hold across runs and between the collector and
+// one-off commands.
+type quotaTracker struct {
+	db       *sql.DB
+	provider string
+	quota    QuotaConfig
+	logger   *log.Logger
+
+	mu     sync.Mutex
+	warned map[string]bool
+}
+
+func openQuotaTracker(path, provider string, quota QuotaConfig) (*quotaTracker, error) {
+	db, err := openDatabase(path, quotaSchema)
+	if err != nil {
+		return nil, err
+	}
+	return &quotaTracker{
+		db:       db,
+		provider: provider,
+		quota:    quota,
+		logger:   log.Default(),
+		warned:   make(map[string]bool),
+	}, nil
+}
+
+var (
+	trackersMu sync.Mutex
+	trackers   = make(map[string]*quotaTracker)
+)
+
+// quotaTrackerFor returns the process-wide quota tracker for a provider in
+// the database at path, opening it on first use, so the clients built for
+// every fetch share one database handle. closeQuotaTrackers closes them.
+func quotaTrackerFor(path, provider string, quota QuotaConfig) (*quotaTracker, error) {
+	trackersMu.Lock()
+	defer trackersMu.Unlock()
+
+	key := path + "\x00" + provider
+	if tracker, ok := trackers[key]; ok {
+		return tracker, nil
+	}
+	tracker, err := openQuotaTracker(path, provider, quota)
+	if err != nil {
+		return nil, err
+	}
+	trackers[key] = tracker
+	return tracker, nil
+}
+
+// closeQuotaTrackers closes every tracker opened by quotaTrackerFor.
+func closeQuotaTrackers() {
+	trackersMu.Lock()
+	defer trackersMu.Unlock()
+
+	for key, tracker := range trackers {
+		tracker.db.Close()
+		delete(trackers, key)
+	}
+}
+
+func quotaPeriods(now time.Time) (day, month string) {
+	now = now.UTC()
+	return now.Format("2006-01-02"), now.Format("2006-01")
+}
+
+// Record counts one call, refusing it with errQuotaExceeded if it would go
+// over the daily or monthly limit.
+func (q *quotaTracker) Record() error {
+	day, month := quotaPeriods(time.Now())
+
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	daily, err := q.increment(tx, day)
+	if err != nil {
+		return err
+	}
+	monthly, err := q.increment(tx, month)
+	if err != nil {
+		return err
+	}
+
+	if q.quota.DailyLimit > 0 && daily > q.quota.DailyLimit {
+		return fmt.Errorf("%w: %d of %d daily %s calls used", errQuotaExceeded, daily-1, q.quota.DailyLimit, q.provider)
+	}
+	if q.quota.MonthlyLimit > 0 && monthly > q.quota.MonthlyLimit {
+		return fmt.Errorf("%w: %d of %d monthly %s calls used", errQuotaExceeded, monthly-1, q.quota.MonthlyLimit, q.provider)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	q.warn("daily", day, daily, q.quota.DailyLimit)
+	q.warn("monthly", month, monthly, q.quota.MonthlyLimit)
+	return nil
+}
+
+func (q *quotaTracker) increment(tx *sql.Tx, period string) (int, error) {
+	var count int
+	err := tx.QueryRow(`INSERT INTO api_calls (provider, period, count) VALUES (?, ?, 1)
+		ON CONFLICT (provider, period) DO UPDATE SET count = count + 1
+		RETURNING count`, q.provider, period).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("could not record API call: %v", err)
+	}
+	return count, nil
+}
+
+// warn logs once per period when usage crosses the warning threshold.
+func (q *quotaTracker) warn(kind, period string, used, limit int) {
+	if limit <= 0 || used*100 < limit*q.quota.WarnPercent {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.warned[period] {
+		return
+	}
+	q.warned[period] = true
+	q.logger.Printf("⚠️  %s has used %d of %d %s calls (%d%%)", q.provider, used, limit, kind, used*100/limit)
+}
+
+// Usage returns the calls made today and this month.
+func (q *quotaTracker) Usage() (daily, monthly int, err error) {
+	day, month := quotaPeriods(time.Now())
+	for period, count := range map[string]*int{day: &daily, month: &monthly} {
+		err := q.db.QueryRow(`SELECT count FROM api_calls WHERE provider = ? AND period = ?`,
+			q.provider, period).Scan(count)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, 0, err
+		}
+	}
+	return daily, monthly, nil
+}
+
+// runQuota implements the "quota" command: print usage against the limits
+// for every provider.
+func runQuota(ctx context.Context, args []string) error {
+	flags := newCommandFlags("quota")
+	flags.Parse(args)
+
+	config, _ := loadConfig()
+	path := databasePath(config)
+
+	fmt.Println("\n📊 API Usage")
+	fmt.Println("============")
+	for _, provider := range providerNames {
+		quota := quotaFor(config, provider)
+		tracker, err := quotaTrackerFor(path, provider, quota)
+		if err != nil {
+			return err
+		}
+		daily, monthly, err := tracker.Usage()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%-15s today: %s  this month: %s\n", provider,
+			formatUsage(daily, quota.DailyLimit), formatUsage(monthly, quota.MonthlyLimit))
+	}
+	return nil
+}
+
+func formatUsage(used, limit int) string {
+	if limit <= 0 {
+		return fmt.Sprintf("%d (no limit)", used)
+	}
+	return fmt.Sprintf("%d/%d", used, limit)
+}
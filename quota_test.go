@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestQuotaTrackerForSharesTrackers(t *testing.T) {
+	t.Cleanup(closeQuotaTrackers)
+	path := filepath.Join(t.TempDir(), "weather.db")
+	quota := QuotaConfig{DailyLimit: 2}
+
+	first, err := quotaTrackerFor(path, providerOpenMeteo, quota)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := quotaTrackerFor(path, providerOpenMeteo, quota)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("got a new tracker for the same database and provider")
+	}
+	other, err := quotaTrackerFor(path, providerWeatherAPI, quota)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == first {
+		t.Error("providers share a tracker")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := first.Record(); err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+	if err := second.Record(); !errors.Is(err, errQuotaExceeded) {
+		t.Errorf("third call: err = %v, want errQuotaExceeded", err)
+	}
+	if daily, _, err := other.Usage(); err != nil || daily != 0 {
+		t.Errorf("other provider used %d calls (%v), want 0", daily, err)
+	}
+
+	closeQuotaTrackers()
+	reopened, err := quotaTrackerFor(path, providerOpenMeteo, quota)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened == first {
+		t.Error("closeQuotaTrackers kept the tracker")
+	}
+	if daily, _, err := reopened.Usage(); err != nil || daily != 2 {
+		t.Errorf("daily = %d (%v) after reopening, want 2", daily, err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// fixture is a recorded provider response.
+type fixture struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Status int             `json:"status"`
+	Header http.Header     `json:"header,omitempty"`
+	Body   json.RawMessage `json:"body"`
+}
+
+var unsafeFixtureChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// fixturePath names the fixture for a request after its host and path so the
+// directory stays browsable, with a hash of the full URL (minus credentials)
+// to tell locations and parameters apart.
+func fixturePath(dir string, req *http.Request) string {
+	target := stripCredentials(req.URL.String())
+	sum := sha256.Sum256([]byte(req.Method + " " + target))
+
+	name := unsafeFixtureChars.ReplaceAllString(req.URL.Host+req.URL.Path, "_")
+	name = strings.Trim(name, "_")
+	return filepath.Join(dir, name+"-"+hex.EncodeToString(sum[:6])+".json")
+}
+
+// recordingTransport passes requests through and saves every response to a
+// fixture file in dir.
+type recordingTransport struct {
+	dir  string
+	next http.RoundTripper
+}
+
+func newRecordingTransport(dir string, next http.RoundTripper) (*recordingTransport, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create record directory: %v", err)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &recordingTransport{dir: dir, next: next}, nil
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Error bodies are not always JSON; store them as a JSON string then.
+	raw := json.RawMessage(body)
+	if !json.Valid(body) {
+		raw, _ = json.Marshal(string(body))
+	}
+
+	data, err := json.MarshalIndent(fixture{
+		Method: req.Method,
+		URL:    stripCredentials(req.URL.String()),
+		Status: resp.StatusCode,
+		Header: recordedHeaders(resp.Header),
+		Body:   raw,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(fixturePath(t.dir, req), data, 0o644); err != nil {
+		return nil, fmt.Errorf("could not write fixture: %v", err)
+	}
+	return resp, nil
+}
+
+// recordedHeaders keeps only the headers the client looks at.
+func recordedHeaders(header http.Header) http.Header {
+	kept := http.Header{}
+	for _, name := range []string{"Content-Type", "ETag", "Last-Modified", "Retry-After"} {
+		if value := header.Get(name); value != "" {
+			kept.Set(name, value)
+		}
+	}
+	return kept
+}
+
+// replayTransport answers requests from fixture files in dir and never
+// touches the network.
+type replayTransport struct {
+	dir string
+}
+
+func newReplayTransport(dir string) (*replayTransport, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not open replay directory: %v", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("replay path %s is not a directory", dir)
+	}
+	return &replayTransport{dir: dir}, nil
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := fixturePath(t.dir, req)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no recorded response for %s %s (%s)",
+			req.Method, stripCredentials(req.URL.String()), filepath.Base(path))
+	}
+
+	var recorded fixture
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("could not parse fixture %s: %v", path, err)
+	}
+
+	body := []byte(recorded.Body)
+	var text string
+	if json.Unmarshal(recorded.Body, &text) == nil {
+		body = []byte(text)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
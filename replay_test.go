@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+const replayFixtures = "testdata/replay"
+
+// noNetwork fails every request that would leave the process.
+type noNetwork struct{ t *testing.T }
+
+func (n noNetwork) RoundTrip(req *http.Request) (*http.Response, error) {
+	n.t.Errorf("unexpected network request to %s", req.URL.Host)
+	return nil, errors.New("network disabled in tests")
+}
+
+// disableNetwork replaces the default transport for the rest of the test.
+func disableNetwork(t *testing.T) {
+	saved := http.DefaultTransport
+	http.DefaultTransport = noNetwork{t}
+	t.Cleanup(func() { http.DefaultTransport = saved })
+}
+
+func TestReplayProviders(t *testing.T) {
+	disableNetwork(t)
+	t.Setenv(envPrefix+"API_KEY", "test-key")
+	ctx := context.Background()
+
+	for _, name := range providerNames {
+		t.Run(name, func(t *testing.T) {
+			provider, err := newProvider(Config{Provider: name, ReplayDir: replayFixtures})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			current, err := provider.CurrentWeather(ctx, "London")
+			if err != nil {
+				t.Fatalf("current: %v", err)
+			}
+			if current.Location.Name != "London" || current.TempC != 11 || current.Humidity != 76 {
+				t.Errorf("current = %+v", current)
+			}
+
+			forecast, err := provider.Forecast(ctx, "London", 3)
+			if err != nil {
+				t.Fatalf("forecast: %v", err)
+			}
+			if len(forecast.Days) == 0 || !forecast.Days[0].Date.Equal(historyDate) {
+				t.Errorf("days = %+v", forecast.Days)
+			}
+
+			history, err := provider.History(ctx, "London", historyDate)
+			if name == providerOpenWeatherMap {
+				if !errors.Is(err, errNotSupported) {
+					t.Errorf("history: err = %v, want errNotSupported", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("history: %v", err)
+			}
+			if len(history.Days) != 1 || len(history.Days[0].Hours) != 2 {
+				t.Errorf("history days = %+v", history.Days)
+			}
+		})
+	}
+}
+
+func TestReplayMissingFixture(t *testing.T) {
+	disableNetwork(t)
+	provider, err := newProvider(Config{Provider: providerOpenMeteo, ReplayDir: replayFixtures})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = provider.CurrentWeather(context.Background(), "Atlantis")
+	if err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("err = %v, want a missing fixture error", err)
+	}
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"temp": 11}`))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	target := server.URL + "/current.json?key=secret&q=London"
+
+	recorder, err := newRecordingTransport(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := stubClient("test")
+	client.http.Transport = recorder
+	var recorded struct{ Temp float64 }
+	if err := client.getJSON(context.Background(), target, &recorded); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("got %d fixtures (%v), want 1", len(entries), err)
+	}
+	data, err := os.ReadFile(dir + "/" + entries[0].Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("fixture contains the API key:\n%s", data)
+	}
+
+	// The key may differ at replay time without missing the fixture.
+	server.Close()
+	disableNetwork(t)
+	replayer, err := newReplayTransport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.http.Transport = replayer
+	var replayed struct{ Temp float64 }
+	if err := client.getJSON(context.Background(), strings.Replace(target, "secret", "other", 1), &replayed); err != nil {
+		t.Fatal(err)
+	}
+	if replayed != recorded {
+		t.Errorf("replayed %+v, recorded %+v", replayed, recorded)
+	}
+}
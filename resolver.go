@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// aviationWeather looks up ICAO airport codes. It needs no key and is
+	// rate limited like a provider.
+	aviationWeather    = "aviationweather"
+	aviationWeatherURL = "https://aviationweather.gov/api/data"
+
+	maxGeocodingResults = 10
+)
+
+var icaoPattern = regexp.MustCompile(`^[A-Z]{4}$`)
+
+// errLocationNotFound is returned when a location matches no known place.
+var errLocationNotFound = errors.New("location not found")
+
+// usStates lets "Paris, TX" select the Texan Paris.
+var usStates = map[string]string{
+	"AL": "Alabama", "AK": "Alaska", "AZ": "Arizona", "AR": "Arkansas", "CA": "California",
+	"CO": "Colorado", "CT": "Connecticut", "DE": "Delaware", "FL": "Florida", "GA": "Georgia",
+	"HI": "Hawaii", "ID": "Idaho", "IL": "Illinois", "IN": "Indiana", "IA": "Iowa",
+	"KS": "Kansas", "KY": "Kentucky", "LA": "Louisiana", "ME": "Maine", "MD": "Maryland",
+	"MA": "Massachusetts", "MI": "Michigan", "MN": "Minnesota", "MS": "Mississippi", "MO": "Missouri",
+	"MT": "Montana", "NE": "Nebraska", "NV": "Nevada", "NH": "New Hampshire", "NJ": "New Jersey",
+	"NM": "New Mexico", "NY": "New York", "NC": "North Carolina", "ND": "North Dakota", "OH": "Ohio",
+	"OK": "Oklahoma", "OR": "Oregon", "PA": "Pennsylvania", "RI": "Rhode Island", "SC": "South Carolina",
+	"SD": "South Dakota", "TN": "Tennessee", "TX": "Texas", "UT": "Utah", "VT": "Vermont",
+	"VA": "Virginia", "WA": "Washington", "WV": "West Virginia", "WI": "Wisconsin", "WY": "Wyoming",
+	"DC": "District of Columbia",
+}
+
+// LocationResolver turns user input into a canonical Location with
+// coordinates and timezone. It accepts saved location aliases, place names (optionally qualified as
+// "Paris, FR" or "Paris, TX"), postal codes, "lat,lon" pairs and ICAO
+// airport codes.
+type LocationResolver struct {
+	geocoding    *apiClient
+	aviation     *apiClient
+	geocodingURL string
+	forecastURL  string
+	aviationURL  string
+
+	// saved are the named locations from the config; they are checked
+	// before anything else.
+	saved []SavedLocation
+
+	// interactive lets the user pick among ambiguous matches; otherwise the
+	// best-ranked match is used.
+	interactive bool
+	in          io.Reader
+	out         io.Writer
+}
+
+func newLocationResolver(config Config, interactive bool) (*LocationResolver, error) {
+	geocoding, err := newConfiguredClient(config, providerOpenMeteo)
+	if err != nil {
+		return nil, err
+	}
+	aviation, err := newConfiguredClient(config, aviationWeather)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocationResolver{
+		geocoding:    geocoding,
+		aviation:     aviation,
+		geocodingURL: openMeteoGeocodingURL,
+		forecastURL:  openMeteoForecastURL,
+		aviationURL:  aviationWeatherURL,
+		saved:        config.Locations,
+		interactive:  interactive,
+		in:           os.Stdin,
+		out:          os.Stderr,
+	}, nil
+}
+
+// stdinIsTerminal reports whether the user can answer prompts.
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Resolve returns the canonical location for query.
+func (r *LocationResolver) Resolve(ctx context.Context, query string) (Location, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return Location{}, fmt.Errorf("empty location")
+	}
+
+	if saved, ok := savedLocation(r.saved, query); ok {
+		return saved.location(), nil
+	}
+
+	if lat, lon, ok := parseCoordinates(query); ok {
+		loc := Location{Name: formatCoordinates(lat, lon), Lat: lat, Lon: lon}
+		loc.Timezone, _ = r.timezoneAt(ctx, lat, lon)
+		return loc, nil
+	}
+
+	if icaoPattern.MatchString(query) {
+		if loc, err := r.airport(ctx, query); err == nil {
+			return loc, nil
+		}
+		// Not an airport after all (e.g. "OSLO"); search it as a name.
+	}
+
+	candidates, err := r.search(ctx, query)
+	if err != nil {
+		return Location{}, err
+	}
+	switch len(candidates) {
+	case 0:
+		return Location{}, fmt.Errorf("%w: %q", errLocationNotFound, query)
+	case 1:
+		return candidates[0], nil
+	}
+
+	if !r.interactive {
+		return candidates[0], nil
+	}
+	return r.choose(query, candidates)
+}
+
+// search geocodes a place name or postal code. Everything after the first
+// comma is a list of qualifiers, such as "NY, US", each narrowing the
+// matches by country code, country, region or US state.
+func (r *LocationResolver) search(ctx context.Context, query string) ([]Location, error) {
+	name, rest, _ := strings.Cut(query, ",")
+	name = strings.TrimSpace(name)
+	var qualifiers []string
+	for _, qualifier := range strings.Split(rest, ",") {
+		if qualifier = strings.TrimSpace(qualifier); qualifier != "" {
+			qualifiers = append(qualifiers, qualifier)
+		}
+	}
+
+	params := url.Values{}
+	params.Set("name", name)
+	params.Set("count", strconv.Itoa(maxGeocodingResults))
+
+	var resp openMeteoGeocodingResponse
+	if err := r.geocoding.getJSON(ctx, r.geocodingURL+"/search?"+params.Encode(), &resp); err != nil {
+		return nil, err
+	}
+
+	var candidates []Location
+	for _, result := range resp.Results {
+		if !matchesQualifiers(qualifiers, result.CountryCode, result.Country, result.Admin1) {
+			continue
+		}
+		candidates = append(candidates, Location{
+			Name:     result.Name,
+			Region:   result.Admin1,
+			Country:  result.Country,
+			Lat:      result.Lat,
+			Lon:      result.Lon,
+			Timezone: result.Timezone,
+		})
+	}
+	return candidates, nil
+}
+
+// matchesQualifiers reports whether a result matches every qualifier.
+func matchesQualifiers(qualifiers []string, countryCode, country, region string) bool {
+	for _, qualifier := range qualifiers {
+		if !matchesQualifier(qualifier, countryCode, country, region) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesQualifier(qualifier, countryCode, country, region string) bool {
+	switch {
+	case strings.EqualFold(qualifier, countryCode),
+		strings.EqualFold(qualifier, country),
+		strings.EqualFold(qualifier, region):
+		return true
+	case strings.EqualFold(countryCode, "US"):
+		return strings.EqualFold(usStates[strings.ToUpper(qualifier)], region)
+	}
+	return false
+}
+
+// airport looks up an ICAO code.
+func (r *LocationResolver) airport(ctx context.Context, code string) (Location, error) {
+	params := url.Values{}
+	params.Set("ids", code)
+	params.Set("format", "json")
+
+	var airports []struct {
+		ICAO    string  `json:"icaoId"`
+		Name    string  `json:"name"`
+		State   string  `json:"state"`
+		Country string  `json:"country"`
+		Lat     float64 `json:"lat"`
+		Lon     float64 `json:"lon"`
+	}
+	if err := r.aviation.getJSON(ctx, r.aviationURL+"/airport?"+params.Encode(), &airports); err != nil {
+		return Location{}, err
+	}
+	if len(airports) == 0 {
+		return Location{}, fmt.Errorf("%w: no airport %s", errLocationNotFound, code)
+	}
+
+	airport := airports[0]
+	loc := Location{
+		Name:    fmt.Sprintf("%s (%s)", airport.Name, airport.ICAO),
+		Region:  airport.State,
+		Country: airport.Country,
+		Lat:     airport.Lat,
+		Lon:     airport.Lon,
+	}
+	loc.Timezone, _ = r.timezoneAt(ctx, loc.Lat, loc.Lon)
+	return loc, nil
+}
+
+// timezoneAt asks Open-Meteo which timezone a coordinate is in.
+func (r *LocationResolver) timezoneAt(ctx context.Context, lat, lon float64) (string, error) {
+	params := url.Values{}
+	params.Set("latitude", strconv.FormatFloat(lat, 'f', -1, 64))
+	params.Set("longitude", strconv.FormatFloat(lon, 'f', -1, 64))
+	params.Set("current", "temperature_2m")
+	params.Set("timezone", "auto")
+
+	var resp struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := r.geocoding.getJSON(ctx, r.forecastURL+"/forecast?"+params.Encode(), &resp); err != nil {
+		return "", err
+	}
+	return resp.Timezone, nil
+}
+
+// choose lets the user pick one of several matches.
+func (r *LocationResolver) choose(query string, candidates []Location) (Location, error) {
+	fmt.Fprintf(r.out, "Several places match %q:\n", query)
+	for i, loc := range candidates {
+		fmt.Fprintf(r.out, "  %d) %s (%.2f, %.2f)\n", i+1, loc.DisplayName(), loc.Lat, loc.Lon)
+	}
+	fmt.Fprintf(r.out, "Choose 1-%d (or press Enter for 1): ", len(candidates))
+
+	line, err := bufio.NewReader(r.in).ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" {
+		if err != nil && err != io.EOF {
+			return Location{}, err
+		}
+		return candidates[0], nil
+	}
+
+	choice, err := strconv.Atoi(line)
+	if err != nil || choice < 1 || choice > len(candidates) {
+		return Location{}, fmt.Errorf("invalid choice %q", line)
+	}
+	return candidates[choice-1], nil
+}
+
+// Query is the string passed to providers for a resolved location.
+// Coordinates are used so every provider looks at the same spot.
+func (l Location) Query() string {
+	if l.Lat == 0 && l.Lon == 0 {
+		return l.Name
+	}
+	return formatCoordinates(l.Lat, l.Lon)
+}
+
+// DisplayName joins name, region and country, skipping empty parts.
+func (l Location) DisplayName() string {
+	parts := []string{l.Name}
+	if l.Region != "" && l.Region != l.Name {
+		parts = append(parts, l.Region)
+	}
+	if l.Country != "" {
+		parts = append(parts, l.Country)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// canonical merges the location a provider reported into a resolved one.
+// The resolved location wins, except that a bare coordinate pair takes the
+// provider's place name when it has one.
+func (l Location) canonical(fetched Location) Location {
+	if _, _, ok := parseCoordinates(l.Name); ok && fetched.Name != "" {
+		if _, _, ok := parseCoordinates(fetched.Name); !ok {
+			l.Name, l.Region, l.Country = fetched.Name, fetched.Region, fetched.Country
+		}
+	}
+	if l.Timezone == "" {
+		l.Timezone = fetched.Timezone
+	}
+	return l
+}
+
+func formatCoordinates(lat, lon float64) string {
+	return strconv.FormatFloat(lat, 'f', 4, 64) + "," + strconv.FormatFloat(lon, 'f', 4, 64)
+}
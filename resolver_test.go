@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// newYorks are geocoding results for "New York" in several countries and
+// states.
+const newYorks = `{"results": [
+	{"name": "New York", "admin1": "England", "country": "United Kingdom", "country_code": "GB", "latitude": 53.08, "longitude": -0.14},
+	{"name": "New York", "admin1": "New York", "country": "United States", "country_code": "US", "latitude": 40.71, "longitude": -74.01},
+	{"name": "New York", "admin1": "Texas", "country": "United States", "country_code": "US", "latitude": 32.17, "longitude": -95.67}
+]}`
+
+func TestSearchQualifiers(t *testing.T) {
+	var names []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		names = append(names, r.URL.Query().Get("name"))
+		w.Write([]byte(newYorks))
+	}))
+	defer server.Close()
+	resolver := &LocationResolver{geocoding: stubClient(providerOpenMeteo), geocodingURL: server.URL}
+
+	tests := []struct {
+		query   string
+		regions []string
+	}{
+		{"New York", []string{"England", "New York", "Texas"}},
+		{"New York, US", []string{"New York", "Texas"}},
+		{"New York, NY", []string{"New York"}},
+		{"New York, NY, US", []string{"New York"}},
+		{"New York, Texas, United States", []string{"Texas"}},
+		{"New York, NY, GB", nil},
+	}
+	for _, tt := range tests {
+		names = nil
+		candidates, err := resolver.search(context.Background(), tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		var regions []string
+		for _, c := range candidates {
+			regions = append(regions, c.Region)
+		}
+		if !slices.Equal(regions, tt.regions) || !slices.Equal(names, []string{"New York"}) {
+			t.Errorf("%s: regions %q after searching %q, want %q", tt.query, regions, names, tt.regions)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// credentialsFileName is looked for next to the user config file when
+	// no credentials_file is configured.
+	credentialsFileName = "credentials"
+
+	credentialsCommandTimeout = 10 * time.Second
+)
+
+var (
+	apiKeysMu sync.Mutex
+	apiKeys   = make(map[string]string)
+)
+
+// getAPIKey returns the key for provider, or "" if none is configured. The
+// sources are tried in order:
+//
+//  1. the WEATHER_API_KEY environment variable
+//  2. the file named by WEATHER_API_KEY_FILE (e.g. a Docker secret)
+//  3. the output of credentials_command
+//  4. the credentials file
+//  5. api_keys and api_key in the config (discouraged)
+//
+// Keys are looked up once per process.
+func getAPIKey(config Config, provider string) (string, error) {
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+
+	if key, ok := apiKeys[provider]; ok {
+		return key, nil
+	}
+	key, err := lookupAPIKey(config, provider)
+	if err != nil {
+		return "", err
+	}
+	apiKeys[provider] = key
+	return key, nil
+}
+
+func lookupAPIKey(config Config, provider string) (string, error) {
+	if key := os.Getenv(envPrefix + "API_KEY"); key != "" {
+		return key, nil
+	}
+
+	if path := os.Getenv(envPrefix + "API_KEY_FILE"); path != "" {
+		// Secret files are managed by the orchestrator, which decides on
+		// their permissions, so they are not checked here.
+		key, err := readCredentialsFile(path, provider, false)
+		if err != nil {
+			return "", fmt.Errorf("%sAPI_KEY_FILE: %v", envPrefix, err)
+		}
+		if key != "" {
+			return key, nil
+		}
+	}
+
+	if config.CredentialsCommand != "" {
+		key, err := runCredentialsCommand(config.CredentialsCommand, provider)
+		if err != nil {
+			return "", err
+		}
+		if key != "" {
+			return key, nil
+		}
+	}
+
+	if path := credentialsFilePath(config); path != "" {
+		key, err := readCredentialsFile(path, provider, true)
+		if err != nil {
+			return "", err
+		}
+		if key != "" {
+			return key, nil
+		}
+	}
+
+	if key := config.APIKeys[provider]; key != "" {
+		return key, nil
+	}
+	return config.APIKey, nil
+}
+
+// credentialsFilePath returns the configured credentials file, expanding a
+// leading ~/, or the default one if it exists.
+func credentialsFilePath(config Config) string {
+	if path := config.CredentialsFile; path != "" {
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, rest)
+			}
+		}
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(dir, "weather-analyzer", credentialsFileName)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// readCredentialsFile reads a key from path. The file holds either a bare
+// key, used for every provider, or "provider=key" lines; blank lines and
+// lines starting with # are skipped. With checkPermissions set the file must
+// not be accessible to other users.
+func readCredentialsFile(path, provider string, checkPermissions bool) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("could not open credentials file: %v", err)
+	}
+	defer file.Close()
+
+	if checkPermissions && runtime.GOOS != "windows" {
+		info, err := file.Stat()
+		if err != nil {
+			return "", err
+		}
+		if mode := info.Mode().Perm(); mode&0o077 != 0 {
+			return "", fmt.Errorf("credentials file %s is accessible by other users (mode %04o); run: chmod 600 %s", path, mode, path)
+		}
+	}
+
+	var fallback string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, key, found := strings.Cut(line, "=")
+		if !found {
+			fallback = line
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(name), provider) {
+			return strings.TrimSpace(key), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("could not read credentials file %s: %v", path, err)
+	}
+	return fallback, nil
+}
+
+// runCredentialsCommand asks an external helper (a password manager, a
+// vault client, ...) for a key. The provider name is passed as the last
+// argument and in WEATHER_PROVIDER; the key is the first line of output.
+func runCredentialsCommand(command, provider string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), credentialsCommandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], append(args[1:], provider)...)
+	cmd.Env = append(os.Environ(), envPrefix+"PROVIDER="+provider)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		// The output is deliberately left out: it may hold the key.
+		return "", fmt.Errorf("credentials_command %s failed: %v", args[0], err)
+	}
+
+	key, _, _ := bytes.Cut(output, []byte("\n"))
+	return strings.TrimSpace(string(key)), nil
+}
+
+// redactedError hides credentials in the message of the error it wraps,
+// while errors.Is and errors.As still see the original.
+type redactedError struct {
+	err     error
+	secrets []string
+}
+
+func (e *redactedError) Error() string {
+	message := e.err.Error()
+	for _, secret := range e.secrets {
+		message = strings.ReplaceAll(message, secret, "[redacted]")
+		message = strings.ReplaceAll(message, url.QueryEscape(secret), "[redacted]")
+	}
+	return message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactURLCredentials wraps err so that no credential from rawURL's query
+// appears in its message.
+func redactURLCredentials(err error, rawURL string) error {
+	if err == nil {
+		return nil
+	}
+	parsed, parseErr := url.Parse(rawURL)
+	if parseErr != nil {
+		return err
+	}
+
+	var secrets []string
+	query := parsed.Query()
+	for _, param := range credentialParams {
+		if value := query.Get(param); value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+	if len(secrets) == 0 {
+		return err
+	}
+	return &redactedError{err: err, secrets: secrets}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultListenAddr = "localhost:8080"
+
+	// maxForecastDays bounds the days parameter; no provider forecasts
+	// further ahead.
+	maxForecastDays = 16
+
+	shutdownTimeout = 5 * time.Second
+)
+
+// contentTypes lists the formats the API can answer in.
+var contentTypes = map[string]string{
+	outputJSON:  "application/json",
+	outputYAML:  "application/yaml",
+	outputCSV:   "text/csv; charset=utf-8",
+	outputTable: "text/plain; charset=utf-8",
+}
+
+// weatherServer answers API requests with one shared provider, so its
+// cache, rate limiter and quota cover every client. As with compare and
+// collect, a saved location's own provider and units are not used.
+type weatherServer struct {
+	provider        WeatherProvider
+	resolver        *LocationResolver
+	timeout         time.Duration
+	defaultLocation string
+}
+
+// runServe implements the "serve" command.
+func runServe(ctx context.Context, args []string) error {
+	config, _ := loadConfig()
+
+	flags := newCommandFlags("serve")
+	addr := flags.String("addr", config.ListenAddr, "host:port to listen on")
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		return usageError("usage: serve [-addr host:port]")
+	}
+
+	provider, err := newProvider(config)
+	if err != nil {
+		return err
+	}
+	// Requests must never wait on a prompt
+	resolver, err := newLocationResolver(config, false)
+	if err != nil {
+		return err
+	}
+	_, timeout, err := fetchSettings(config)
+	if err != nil {
+		return err
+	}
+
+	server := &weatherServer{
+		provider:        provider,
+		resolver:        resolver,
+		timeout:         timeout,
+		defaultLocation: config.City,
+	}
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           server.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- httpServer.ListenAndServe()
+	}()
+	log.Printf("🌐 Serving the %s API on http://%s (Ctrl-C to stop)", provider.Name(), *addr)
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("could not serve on %s: %w", *addr, err)
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return httpServer.Shutdown(shutdownCtx)
+}
+
+func (s *weatherServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.Handle("/v1/current", s.endpoint(s.current))
+	mux.Handle("/v1/forecast", s.endpoint(s.forecast))
+	mux.Handle("/v1/history", s.endpoint(s.history))
+	mux.Handle("/v1/readings", s.endpoint(s.readings))
+	mux.Handle("/v1/analysis", s.endpoint(s.analysis))
+	mux.Handle("/metrics", metricsHandler())
+	return logRequests(mux)
+}
+
+// endpoint adapts a handler that returns a document to HTTP: it checks the
+// method and format and turns errors into JSON error responses.
+func (s *weatherServer) endpoint(handle func(r *http.Request, loc Location) (document, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, http.StatusMethodNotAllowed, errors.New("only GET is supported"))
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = outputJSON
+		}
+		contentType, ok := contentTypes[format]
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("unknown format %q; use json, yaml, csv or table", format))
+			return
+		}
+
+		location := r.URL.Query().Get("location")
+		if location == "" {
+			location = s.defaultLocation
+		}
+		loc, err := s.resolver.Resolve(r.Context(), location)
+		if err != nil {
+			writeError(w, statusFor(err), err)
+			return
+		}
+
+		doc, err := handle(r, loc)
+		if err != nil {
+			writeError(w, statusFor(err), err)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		if err := writeDocument(w, format, doc); err != nil {
+			log.Printf("⚠️  Could not write response: %v", err)
+		}
+	})
+}
+
+// current serves GET /v1/current?location=.
+func (s *weatherServer) current(r *http.Request, loc Location) (document, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	weather, err := s.provider.CurrentWeather(ctx, loc.Query())
+	if err != nil {
+		return nil, err
+	}
+	weather.Location = loc.canonical(weather.Location)
+	addAirQuality(ctx, s.provider, loc.Query(), weather)
+	storeReading(weather)
+	return newCurrentDocument(weather, officialAlerts(ctx, s.provider, loc.Query())), nil
+}
+
+// forecast serves GET /v1/forecast?location=&days=.
+func (s *weatherServer) forecast(r *http.Request, loc Location) (document, error) {
+	days := 7
+	if value := r.URL.Query().Get("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxForecastDays {
+			return nil, usageError("days must be a whole number from 1 to %d, got %q", maxForecastDays, value)
+		}
+		days = n
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	forecast, err := s.provider.Forecast(ctx, loc.Query(), days)
+	if err != nil {
+		return nil, err
+	}
+	forecast.Location = loc.canonical(forecast.Location)
+	forecast.Current.Location = forecast.Location
+	addAirQuality(ctx, s.provider, loc.Query(), &forecast.Current)
+	storeReading(&forecast.Current)
+	return newForecastDocument(forecast), nil
+}
+
+// history serves GET /v1/history?location=&date=, the provider's record of
+// a past day (yesterday by default).
+func (s *weatherServer) history(r *http.Request, loc Location) (document, error) {
+	date := time.Now().AddDate(0, 0, -1)
+	if value := r.URL.Query().Get("date"); value != "" {
+		parsed, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return nil, usageError("invalid date %q; use YYYY-MM-DD", value)
+		}
+		if parsed.After(time.Now()) {
+			return nil, usageError("date %s is in the future", value)
+		}
+		date = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	history, err := s.provider.History(ctx, loc.Query(), date)
+	if err != nil {
+		return nil, err
+	}
+	history.Location = loc.canonical(history.Location)
+	if len(history.Days) == 0 {
+		return nil, fmt.Errorf("no history available for %s on %s", loc.DisplayName(), date.Format(time.DateOnly))
+	}
+	return newHistoryDocument(history.Location, history.Days[0]), nil
+}
+
+// readings serves GET /v1/readings?location=&period=, the readings stored
+// by earlier requests and runs.
+func (s *weatherServer) readings(r *http.Request, loc Location) (document, error) {
+	period, err := periodParam(r)
+	if err != nil {
+		return nil, err
+	}
+	readings, err := loadWeatherData(loc, period)
+	if err != nil {
+		return nil, fmt.Errorf("could not load weather data: %v", err)
+	}
+	return newReadingsDocument(loc, period, readings), nil
+}
+
+// analysis serves GET /v1/analysis?location=&period=.
+func (s *weatherServer) analysis(r *http.Request, loc Location) (document, error) {
+	period, err := periodParam(r)
+	if err != nil {
+		return nil, err
+	}
+	readings, err := loadWeatherData(loc, period)
+	if err != nil {
+		return nil, fmt.Errorf("could not load weather data: %v", err)
+	}
+	return newAnalysisDocument(loc, period, readings), nil
+}
+
+// periodParam reads the period parameter, a Go duration defaulting to a
+// week.
+func periodParam(r *http.Request) (time.Duration, error) {
+	value := r.URL.Query().Get("period")
+	if value == "" {
+		return 7 * 24 * time.Hour, nil
+	}
+	period, err := time.ParseDuration(value)
+	if err != nil || period <= 0 {
+		return 0, usageError("period must be a positive duration such as \"24h\", got %q", value)
+	}
+	return period, nil
+}
+
+// statusFor maps an error to an HTTP status by the same classes as the
+// command line exit codes. Upstream failures are the server's problem, not
+// the client's, so they become 502 or 504.
+func statusFor(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	if errors.Is(err, errQuotaExceeded) {
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, errNotSupported) {
+		return http.StatusNotImplemented
+	}
+	switch exitCode(err) {
+	case exitUsage:
+		return http.StatusBadRequest
+	case exitNotFound:
+		return http.StatusNotFound
+	case exitNetwork, exitAuth:
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// statusRecorder remembers the status a handler wrote, for the access log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		log.Printf("%s %s %d %s", r.Method, r.URL.RequestURI(), recorder.status, time.Since(start).Round(time.Millisecond))
+	})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProvider answers every forecast with a copy of forecast and records
+// the last location and days it was asked for.
+type fakeProvider struct {
+	forecast Forecast
+
+	location string
+	days     int
+}
+
+func (f *fakeProvider) Name() string { return "fake" }
+
+func (f *fakeProvider) CurrentWeather(ctx context.Context, location string) (*WeatherData, error) {
+	f.location = location
+	current := f.forecast.Current
+	return &current, nil
+}
+
+func (f *fakeProvider) Forecast(ctx context.Context, location string, days int) (*Forecast, error) {
+	f.location, f.days = location, days
+	forecast := f.forecast
+	return &forecast, nil
+}
+
+func (f *fakeProvider) History(ctx context.Context, location string, date time.Time) (*Forecast, error) {
+	return nil, fmt.Errorf("history for %s: %w", f.Name(), errNotSupported)
+}
+
+func (f *fakeProvider) Alerts(ctx context.Context, location string) ([]WeatherAlert, error) {
+	return f.forecast.Alerts, nil
+}
+
+func (f *fakeProvider) AirQuality(ctx context.Context, location string) (*AirQuality, error) {
+	return f.forecast.Current.AirQuality, nil
+}
+
+var yard = SavedLocation{Alias: "yard", Name: "Yard", Lat: 51.5, Lon: -0.12, Timezone: "UTC"}
+
+// newTestServer serves the API for provider at the saved location yard,
+// with readings stored in a temporary database.
+func newTestServer(t *testing.T, provider WeatherProvider) *httptest.Server {
+	t.Helper()
+	t.Setenv(envPrefix+"DATABASE_PATH", filepath.Join(t.TempDir(), "weather.db"))
+	server := &weatherServer{
+		provider:        provider,
+		resolver:        &LocationResolver{saved: []SavedLocation{yard}},
+		timeout:         time.Second,
+		defaultLocation: yard.Alias,
+	}
+	httpServer := httptest.NewServer(server.routes())
+	t.Cleanup(httpServer.Close)
+	return httpServer
+}
+
+// getDocument requests path and decodes the JSON answer into doc, failing
+// unless the status is want.
+func getDocument(t *testing.T, server *httptest.Server, path string, want int, doc any) {
+	t.Helper()
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != want {
+		t.Fatalf("GET %s: status %d, want %d", path, resp.StatusCode, want)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(doc); err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+}
+
+func TestServeCurrent(t *testing.T) {
+	provider := &fakeProvider{forecast: Forecast{Current: WeatherData{TempC: 11, Timestamp: time.Now()}}}
+	server := newTestServer(t, provider)
+
+	var doc struct {
+		Kind     string
+		Location Location
+		Current  WeatherData
+	}
+	getDocument(t, server, "/v1/current", http.StatusOK, &doc)
+	if doc.Kind != "current" || doc.Location.Name != "Yard" || doc.Current.TempC != 11 {
+		t.Errorf("document = %+v", doc)
+	}
+	if provider.location != yard.location().Query() {
+		t.Errorf("provider asked for %q, want the saved coordinates", provider.location)
+	}
+
+	// The reading was stored, so it is in the history.
+	var readings struct{ Readings []WeatherData }
+	getDocument(t, server, "/v1/readings?location=yard&period=1h", http.StatusOK, &readings)
+	if len(readings.Readings) != 1 || readings.Readings[0].TempC != 11 {
+		t.Errorf("readings = %+v", readings.Readings)
+	}
+}
+
+func TestServeForecastDays(t *testing.T) {
+	provider := &fakeProvider{forecast: Forecast{Days: []ForecastDay{{MaxTempC: 12}}}}
+	server := newTestServer(t, provider)
+
+	var doc struct {
+		Kind string
+		Days []ForecastDay
+	}
+	getDocument(t, server, "/v1/forecast?days=3", http.StatusOK, &doc)
+	if doc.Kind != "forecast" || len(doc.Days) != 1 || provider.days != 3 {
+		t.Errorf("document = %+v after asking for %d days", doc, provider.days)
+	}
+
+	for _, days := range []string{"0", "17", "soon"} {
+		var failure struct{ Error string }
+		getDocument(t, server, "/v1/forecast?days="+days, http.StatusBadRequest, &failure)
+		if !strings.Contains(failure.Error, "days") {
+			t.Errorf("days=%s: error = %q", days, failure.Error)
+		}
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	server := newTestServer(t, &fakeProvider{})
+
+	var failure struct{ Error string }
+	getDocument(t, server, "/v1/history", http.StatusNotImplemented, &failure)
+	getDocument(t, server, "/v1/current?format=xml", http.StatusBadRequest, &failure)
+	getDocument(t, server, "/v1/analysis?period=-1h", http.StatusBadRequest, &failure)
+
+	resp, err := http.Post(server.URL+"/v1/current", "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET, HEAD" {
+		t.Errorf("POST: status %d, Allow %q", resp.StatusCode, resp.Header.Get("Allow"))
+	}
+}
+
+func TestServeFormats(t *testing.T) {
+	server := newTestServer(t, &fakeProvider{forecast: Forecast{Current: WeatherData{TempC: 11}}})
+
+	resp, err := http.Get(server.URL + "/v1/current?format=csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != contentTypes[outputCSV] {
+		t.Errorf("Content-Type = %q", got)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(body), "location,") || !strings.Contains(string(body), "Yard,") {
+		t.Errorf("csv body = %q", body)
+	}
+}
@@ -1,61 +1,414 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"time"
+
+	_ "modernc.org/sqlite"
 )
 
-const dataFile = "weather_data.json"
+const (
+	defaultDatabasePath = "weather.db"
 
-func storeWeatherData(weather *WeatherData) error {
-	// Load existing data
-	var allData []WeatherData
-	file, err := os.Open(dataFile)
-	if err == nil {
-		json.NewDecoder(file).Decode(&allData)
-		file.Close()
-	}
+	// dataFile is the JSON file earlier versions wrote readings to; it can be
+	// imported into the database with importWeatherDataFile.
+	dataFile = "weather_data.json"
+)
 
-	// Add new data
-	allData = append(allData, *weather)
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS observations (
+	location_id TEXT    NOT NULL,
+	location    TEXT    NOT NULL COLLATE NOCASE,
+	timestamp   INTEGER NOT NULL,
+	region      TEXT    NOT NULL DEFAULT '',
+	country     TEXT    NOT NULL DEFAULT '',
+	lat         REAL    NOT NULL DEFAULT 0,
+	lon         REAL    NOT NULL DEFAULT 0,
+	timezone    TEXT    NOT NULL DEFAULT '',
+	temp_c      REAL    NOT NULL,
+	feelslike_c REAL    NOT NULL,
+	humidity    INTEGER NOT NULL,
+	wind_kph    REAL    NOT NULL,
+	wind_degree INTEGER NOT NULL,
+	pressure_mb REAL    NOT NULL,
+	precip_mm   REAL    NOT NULL,
+	condition   TEXT    NOT NULL,
+	PRIMARY KEY (location_id, timestamp)
+);
+CREATE INDEX IF NOT EXISTS observations_timestamp ON observations (timestamp);
+CREATE TABLE IF NOT EXISTS air_quality (
+	location_id TEXT    NOT NULL,
+	timestamp   INTEGER NOT NULL,
+	pm2_5_ug    REAL    NOT NULL,
+	pm10_ug     REAL    NOT NULL,
+	o3_ug       REAL    NOT NULL,
+	no2_ug      REAL    NOT NULL,
+	so2_ug      REAL    NOT NULL,
+	co_ug       REAL    NOT NULL,
+	PRIMARY KEY (location_id, timestamp)
+);
+`
 
-	// Keep only last 24 hours of data
-	allData = filterRecentData(allData)
+// readingColumns are the columns Range and the migration scan readings
+// from, in the order scanReadings expects.
+const readingColumns = `
+	o.location, o.timestamp, region, country, lat, lon, timezone,
+	temp_c, feelslike_c, humidity, wind_kph, wind_degree, pressure_mb, precip_mm, condition,
+	pm2_5_ug, pm10_ug, o3_ug, no2_ug, so2_ug, co_ug`
 
-	// Save back to file
-	file, err = os.Create(dataFile)
+// WeatherStore keeps observations in an embedded SQLite database keyed by
+// location ID (see storageID) and observation time.
+type WeatherStore struct {
+	db        *sql.DB
+	retention time.Duration
+}
+
+// openDatabase opens the SQLite file at path and creates schema in it. The
+// store and the quota tracker share the file, possibly from several
+// processes, so writers wait for the lock instead of failing with
+// SQLITE_BUSY.
+func openDatabase(path, schema string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)")
 	if err != nil {
-		return fmt.Errorf("could not create data file: %v", err)
+		return nil, fmt.Errorf("could not open database: %v", err)
 	}
-	defer file.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not create schema: %v", err)
+	}
+	return db, nil
+}
+
+// openWeatherStore opens (or creates) the database at path. Readings older
+// than retention are pruned on write; zero keeps everything.
+func openWeatherStore(path string, retention time.Duration) (*WeatherStore, error) {
+	db, err := openDatabase(path, storeSchema)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrateStore(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &WeatherStore{db: db, retention: retention}, nil
+}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(allData)
+// storageID identifies a location in the store. Places with coordinates
+// are keyed on them, rounded to about a kilometre, so that Paris, FR and
+// Paris, TX stay apart; readings without coordinates fall back to name,
+// region and country.
+func (l Location) storageID() string {
+	if l.Lat != 0 || l.Lon != 0 {
+		return fmt.Sprintf("%.2f,%.2f", l.Lat, l.Lon)
+	}
+	return strings.ToLower(l.Name + "|" + l.Region + "|" + l.Country)
 }
 
-func filterRecentData(data []WeatherData) []WeatherData {
-	var recent []WeatherData
-	cutoff := time.Now().Add(-24 * time.Hour)
-	
-	for _, item := range data {
-		if item.Timestamp.After(cutoff) {
-			recent = append(recent, item)
+// migrateStore rekeys databases written before readings had a location ID,
+// when they were keyed on the location name alone.
+func migrateStore(db *sql.DB) error {
+	keyed, err := hasColumn(db, "observations", "location_id")
+	if err != nil || keyed {
+		return err
+	}
+	// Databases from before air quality was stored got the current
+	// air_quality table from the schema; it is still empty.
+	legacyAirQuality, err := hasColumn(db, "air_quality", "location")
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rename := `DROP INDEX observations_timestamp; ALTER TABLE observations RENAME TO observations_v1;`
+	join := `LEFT JOIN air_quality a ON 0`
+	if legacyAirQuality {
+		rename += `ALTER TABLE air_quality RENAME TO air_quality_v1;`
+		join = `LEFT JOIN air_quality_v1 a ON a.location = o.location AND a.timestamp = o.timestamp`
+	}
+	if _, err := tx.Exec(rename + storeSchema); err != nil {
+		return fmt.Errorf("could not migrate database: %v", err)
+	}
+
+	rows, err := tx.Query(`SELECT` + readingColumns + ` FROM observations_v1 o ` + join)
+	if err != nil {
+		return fmt.Errorf("could not migrate database: %v", err)
+	}
+	data, err := scanReadings(rows)
+	if err != nil {
+		return fmt.Errorf("could not migrate database: %v", err)
+	}
+	s := &WeatherStore{}
+	for _, weather := range data {
+		if err := s.insert(tx, weather); err != nil {
+			return err
 		}
 	}
-	return recent
+
+	drop := `DROP TABLE observations_v1;`
+	if legacyAirQuality {
+		drop += `DROP TABLE air_quality_v1;`
+	}
+	if _, err := tx.Exec(drop); err != nil {
+		return fmt.Errorf("could not migrate database: %v", err)
+	}
+	return tx.Commit()
+}
+
+// hasColumn reports whether table has column.
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	var found bool
+	err := db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&found)
+	if err != nil {
+		return false, fmt.Errorf("could not inspect database: %v", err)
+	}
+	return found, nil
+}
+
+// databasePath returns the configured database file.
+func databasePath(config Config) string {
+	if config.DatabasePath == "" {
+		return defaultDatabasePath
+	}
+	return config.DatabasePath
+}
+
+// openConfiguredStore opens the store described by the config.
+func openConfiguredStore(config Config) (*WeatherStore, error) {
+	return openWeatherStore(databasePath(config), time.Duration(config.RetentionDays)*24*time.Hour)
+}
+
+func (s *WeatherStore) Close() error {
+	return s.db.Close()
+}
+
+// Save stores a reading, replacing any earlier one for the same location
+// and time.
+func (s *WeatherStore) Save(weather WeatherData) error {
+	if err := s.insert(s.db, weather); err != nil {
+		return err
+	}
+	_, err := s.Prune()
+	return err
 }
 
-func loadWeatherData() ([]WeatherData, error) {
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func (s *WeatherStore) insert(db execer, weather WeatherData) error {
+	loc := weather.Location
+	id := loc.storageID()
+	_, err := db.Exec(`INSERT OR REPLACE INTO observations (
+		location_id, location, timestamp, region, country, lat, lon, timezone,
+		temp_c, feelslike_c, humidity, wind_kph, wind_degree, pressure_mb, precip_mm, condition
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, loc.Name, weather.Timestamp.Unix(), loc.Region, loc.Country, loc.Lat, loc.Lon, loc.Timezone,
+		weather.TempC, weather.FeelsLikeC, weather.Humidity, weather.WindKph, weather.WindDegree,
+		weather.PressureMb, weather.PrecipMm, weather.Condition)
+	if err != nil {
+		return fmt.Errorf("could not store reading: %v", err)
+	}
+
+	// Air quality has a table of its own so databases from before it
+	// existed need no migration.
+	if aq := weather.AirQuality; aq != nil {
+		_, err = db.Exec(`INSERT OR REPLACE INTO air_quality (
+			location_id, timestamp, pm2_5_ug, pm10_ug, o3_ug, no2_ug, so2_ug, co_ug
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, weather.Timestamp.Unix(), aq.PM25Ug, aq.PM10Ug, aq.O3Ug, aq.NO2Ug, aq.SO2Ug, aq.COUg)
+	} else {
+		_, err = db.Exec(`DELETE FROM air_quality WHERE location_id = ? AND timestamp = ?`, id, weather.Timestamp.Unix())
+	}
+	if err != nil {
+		return fmt.Errorf("could not store air quality: %v", err)
+	}
+	return nil
+}
+
+// Range returns readings taken at loc between from and to (inclusive),
+// oldest first. A zero location matches every location. Readings stored
+// without coordinates, such as imported flat City records, match by name.
+func (s *WeatherStore) Range(loc Location, from, to time.Time) ([]WeatherData, error) {
+	rows, err := s.db.Query(`SELECT`+readingColumns+`
+	FROM observations o
+	LEFT JOIN air_quality a ON a.location_id = o.location_id AND a.timestamp = o.timestamp
+	WHERE (? OR o.location_id = ? OR (o.lat = 0 AND o.lon = 0 AND o.location = ?))
+		AND o.timestamp BETWEEN ? AND ?
+	ORDER BY o.timestamp, o.location`,
+		loc == Location{}, loc.storageID(), loc.Name, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("could not query readings: %v", err)
+	}
+	return scanReadings(rows)
+}
+
+// scanReadings reads rows selected with readingColumns and closes them.
+func scanReadings(rows *sql.Rows) ([]WeatherData, error) {
+	defer rows.Close()
+
 	var data []WeatherData
-	file, err := os.Open(dataFile)
+	for rows.Next() {
+		var weather WeatherData
+		var timestamp int64
+		var pm25, pm10, o3, no2, so2, co sql.NullFloat64
+		loc := &weather.Location
+		err := rows.Scan(&loc.Name, &timestamp, &loc.Region, &loc.Country, &loc.Lat, &loc.Lon, &loc.Timezone,
+			&weather.TempC, &weather.FeelsLikeC, &weather.Humidity, &weather.WindKph, &weather.WindDegree,
+			&weather.PressureMb, &weather.PrecipMm, &weather.Condition,
+			&pm25, &pm10, &o3, &no2, &so2, &co)
+		if err != nil {
+			return nil, fmt.Errorf("could not read reading: %v", err)
+		}
+		weather.Timestamp = time.Unix(timestamp, 0)
+		if pm25.Valid {
+			weather.AirQuality = &AirQuality{PM25Ug: pm25.Float64, PM10Ug: pm10.Float64, O3Ug: o3.Float64,
+				NO2Ug: no2.Float64, SO2Ug: so2.Float64, COUg: co.Float64}
+		}
+		data = append(data, weather)
+	}
+	return data, rows.Err()
+}
+
+// Locations returns the places readings are stored for under name, ignoring
+// case, most recently read first.
+func (s *WeatherStore) Locations(name string) ([]Location, error) {
+	rows, err := s.db.Query(`SELECT location, region, country, lat, lon, timezone, MAX(timestamp)
+	FROM observations WHERE location = ?
+	GROUP BY location_id ORDER BY MAX(timestamp) DESC`, name)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("could not query locations: %v", err)
+	}
+	defer rows.Close()
+
+	var locations []Location
+	for rows.Next() {
+		var loc Location
+		var latest int64
+		if err := rows.Scan(&loc.Name, &loc.Region, &loc.Country, &loc.Lat, &loc.Lon, &loc.Timezone, &latest); err != nil {
+			return nil, fmt.Errorf("could not read location: %v", err)
+		}
+		locations = append(locations, loc)
+	}
+	return locations, rows.Err()
+}
+
+// Prune deletes readings older than the retention period.
+func (s *WeatherStore) Prune() (int64, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+
+	cutoff := time.Now().Add(-s.retention).Unix()
+	result, err := s.db.Exec(`DELETE FROM observations WHERE timestamp < ?`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("could not prune readings: %v", err)
+	}
+	if _, err := s.db.Exec(`DELETE FROM air_quality WHERE timestamp < ?`, cutoff); err != nil {
+		return 0, fmt.Errorf("could not prune readings: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// legacyReading accepts both canonical readings and the flat City/Temp
+// records earlier versions wrote.
+type legacyReading struct {
+	WeatherData
+	City string   `json:"City"`
+	Temp *float64 `json:"Temp"`
+}
+
+// ImportJSON loads readings from a weather_data.json file written by earlier
+// versions and returns how many were imported.
+func (s *WeatherStore) ImportJSON(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
 	}
 	defer file.Close()
 
-	err = json.NewDecoder(file).Decode(&data)
-	return data, err
-}
\ No newline at end of file
+	var data []legacyReading
+	if err := json.NewDecoder(file).Decode(&data); err != nil {
+		return 0, fmt.Errorf("could not decode %s: %v", path, err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	for _, reading := range data {
+		weather := reading.WeatherData
+		if reading.City != "" {
+			weather.Location.Name = reading.City
+		}
+		if reading.Temp != nil {
+			weather.TempC = *reading.Temp
+		}
+		if err := s.insert(tx, weather); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func storeWeatherData(weather *WeatherData) error {
+	config, _ := loadConfig()
+	store, err := openConfiguredStore(config)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return store.Save(*weather)
+}
+
+// loadWeatherData returns the readings for loc taken within the last
+// period. A zero location loads every location.
+func loadWeatherData(loc Location, period time.Duration) ([]WeatherData, error) {
+	config, _ := loadConfig()
+	store, err := openConfiguredStore(config)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	now := time.Now()
+	return store.Range(loc, now.Add(-period), now)
+}
+
+// storedLocations returns the places readings are stored for under name.
+func storedLocations(name string) ([]Location, error) {
+	config, _ := loadConfig()
+	store, err := openConfiguredStore(config)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	return store.Locations(name)
+}
+
+func importWeatherDataFile(path string) (int, error) {
+	config, _ := loadConfig()
+	store, err := openConfiguredStore(config)
+	if err != nil {
+		return 0, err
+	}
+	defer store.Close()
+
+	return store.ImportJSON(path)
+}
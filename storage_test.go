@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	parisFR = Location{Name: "Paris", Region: "Ile-de-France", Country: "France", Lat: 48.8534, Lon: 2.3488}
+	parisTX = Location{Name: "Paris", Region: "Texas", Country: "United States", Lat: 33.6609, Lon: -95.5555}
+)
+
+func testStore(t *testing.T, path string) *WeatherStore {
+	t.Helper()
+	store, err := openWeatherStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestStoreKeepsPlacesWithTheSameNameApart(t *testing.T) {
+	store := testStore(t, filepath.Join(t.TempDir(), "weather.db"))
+	at := time.Unix(1_700_000_000, 0)
+	for _, weather := range []WeatherData{
+		{Location: parisFR, Timestamp: at, TempC: 12, AirQuality: &AirQuality{PM25Ug: 8}},
+		{Location: parisTX, Timestamp: at, TempC: 25},
+	} {
+		if err := store.Save(weather); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		loc  Location
+		want float64
+	}{{parisFR, 12}, {parisTX, 25}} {
+		data, err := store.Range(tt.loc, at, at)
+		if err != nil || len(data) != 1 || data[0].TempC != tt.want || data[0].Location.Region != tt.loc.Region {
+			t.Errorf("%s: readings = %+v, %v", tt.loc.DisplayName(), data, err)
+			continue
+		}
+		if gotAQ := data[0].AirQuality != nil; gotAQ != (tt.loc == parisFR) {
+			t.Errorf("%s: air quality = %+v", tt.loc.DisplayName(), data[0].AirQuality)
+		}
+	}
+	if data, err := store.Range(Location{}, at, at); err != nil || len(data) != 2 {
+		t.Errorf("every location: %d readings, %v", len(data), err)
+	}
+}
+
+func TestStoreMatchesReadingsWithoutCoordinatesByName(t *testing.T) {
+	store := testStore(t, filepath.Join(t.TempDir(), "weather.db"))
+	at := time.Unix(1_700_000_000, 0)
+	if err := store.Save(WeatherData{Location: Location{Name: "paris"}, Timestamp: at, TempC: 9}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := store.Range(parisFR, at, at); err != nil || len(data) != 1 || data[0].TempC != 9 {
+		t.Errorf("readings = %+v, %v", data, err)
+	}
+}
+
+func TestStoreMigratesNameKeyedDatabases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weather.db")
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+	CREATE TABLE observations (
+		location    TEXT    NOT NULL COLLATE NOCASE,
+		timestamp   INTEGER NOT NULL,
+		region      TEXT    NOT NULL DEFAULT '',
+		country     TEXT    NOT NULL DEFAULT '',
+		lat         REAL    NOT NULL DEFAULT 0,
+		lon         REAL    NOT NULL DEFAULT 0,
+		timezone    TEXT    NOT NULL DEFAULT '',
+		temp_c      REAL    NOT NULL,
+		feelslike_c REAL    NOT NULL,
+		humidity    INTEGER NOT NULL,
+		wind_kph    REAL    NOT NULL,
+		wind_degree INTEGER NOT NULL,
+		pressure_mb REAL    NOT NULL,
+		precip_mm   REAL    NOT NULL,
+		condition   TEXT    NOT NULL,
+		PRIMARY KEY (location, timestamp)
+	);
+	CREATE INDEX observations_timestamp ON observations (timestamp);
+	CREATE TABLE air_quality (
+		location  TEXT    NOT NULL COLLATE NOCASE,
+		timestamp INTEGER NOT NULL,
+		pm2_5_ug  REAL    NOT NULL,
+		pm10_ug   REAL    NOT NULL,
+		o3_ug     REAL    NOT NULL,
+		no2_ug    REAL    NOT NULL,
+		so2_ug    REAL    NOT NULL,
+		co_ug     REAL    NOT NULL,
+		PRIMARY KEY (location, timestamp)
+	);
+	INSERT INTO observations VALUES
+		('Paris', 1700000000, 'Ile-de-France', 'France', 48.8534, 2.3488, 'Europe/Paris', 12, 11, 80, 10, 180, 1012, 0, 'Cloudy');
+	INSERT INTO air_quality VALUES ('Paris', 1700000000, 8, 15, 60, 20, 2, 200);`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Opening twice checks the migration runs only once.
+	testStore(t, path).Close()
+	store := testStore(t, path)
+	at := time.Unix(1_700_000_000, 0)
+	data, err := store.Range(parisFR, at, at)
+	if err != nil || len(data) != 1 || data[0].TempC != 12 || data[0].AirQuality == nil || data[0].AirQuality.PM25Ug != 8 {
+		t.Fatalf("readings = %+v, %v", data, err)
+	}
+
+	// Paris, TX no longer replaces the migrated reading.
+	if err := store.Save(WeatherData{Location: parisTX, Timestamp: at, TempC: 25}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := store.Range(parisFR, at, at); err != nil || len(data) != 1 || data[0].TempC != 12 {
+		t.Errorf("after saving Paris, TX: %+v, %v", data, err)
+	}
+}
+
+func TestStoreLocationsByName(t *testing.T) {
+	store := testStore(t, filepath.Join(t.TempDir(), "weather.db"))
+	at := time.Unix(1_700_000_000, 0)
+	for _, weather := range []WeatherData{
+		{Location: parisFR, Timestamp: at, TempC: 12},
+		{Location: parisFR, Timestamp: at.Add(-time.Hour), TempC: 11},
+		{Location: parisTX, Timestamp: at.Add(time.Hour), TempC: 25},
+		{Location: Location{Name: "London", Lat: 51.5, Lon: -0.12}, Timestamp: at, TempC: 8},
+	} {
+		if err := store.Save(weather); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	locations, err := store.Locations("PARIS")
+	if err != nil || len(locations) != 2 || locations[0] != parisTX || locations[1] != parisFR {
+		t.Errorf("Paris: %+v, %v; want Texas, read last, then France", locations, err)
+	}
+	if locations, err := store.Locations("Lyon"); err != nil || len(locations) != 0 {
+		t.Errorf("Lyon: %+v, %v", locations, err)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Units for each kind of measurement. The canonical model is always metric
+// (see model.go); a UnitSystem only converts values for display and export.
+const (
+	unitCelsius    = "c"
+	unitFahrenheit = "f"
+	unitKelvin     = "k"
+
+	unitKph   = "kph"
+	unitMph   = "mph"
+	unitMs    = "ms"
+	unitKnots = "kn"
+
+	unitHPa  = "hpa"
+	unitKPa  = "kpa"
+	unitInHg = "inhg"
+
+	unitMm   = "mm"
+	unitInch = "in"
+)
+
+// UnitSystem selects the unit each measurement is shown in. The zero value
+// is metric.
+type UnitSystem struct {
+	TempUnit     string
+	SpeedUnit    string
+	PressureUnit string
+	PrecipUnit   string
+}
+
+var unitPresets = map[string]UnitSystem{
+	"metric":   {TempUnit: unitCelsius, SpeedUnit: unitKph, PressureUnit: unitHPa, PrecipUnit: unitMm},
+	"imperial": {TempUnit: unitFahrenheit, SpeedUnit: unitMph, PressureUnit: unitInHg, PrecipUnit: unitInch},
+	"si":       {TempUnit: unitKelvin, SpeedUnit: unitMs, PressureUnit: unitKPa, PrecipUnit: unitMm},
+	"uk":       {TempUnit: unitCelsius, SpeedUnit: unitMph, PressureUnit: unitHPa, PrecipUnit: unitMm},
+}
+
+// unitChoices lists the accepted units per measurement, keyed by every name
+// the measurement can be overridden with.
+var unitChoices = map[string][]string{
+	"temperature":   {unitCelsius, unitFahrenheit, unitKelvin},
+	"speed":         {unitKph, unitMph, unitMs, unitKnots},
+	"pressure":      {unitHPa, unitKPa, unitInHg},
+	"precipitation": {unitMm, unitInch},
+}
+
+var unitAliases = map[string]string{
+	"temp":   "temperature",
+	"wind":   "speed",
+	"precip": "precipitation",
+	"rain":   "precipitation",
+}
+
+// parseUnits reads a units setting: a preset (metric, imperial, si, uk)
+// optionally followed by per-measurement overrides, e.g.
+// "metric,wind=mph" or "imperial,temp=c". An empty string is metric.
+func parseUnits(spec string) (UnitSystem, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(spec)), ",")
+
+	preset := strings.TrimSpace(parts[0])
+	if preset == "" {
+		preset = "metric"
+	}
+	units, ok := unitPresets[preset]
+	if !ok {
+		return UnitSystem{}, fmt.Errorf("unknown unit system %q; use one of %s", preset, strings.Join(sortedKeys(unitPresets), ", "))
+	}
+
+	for _, part := range parts[1:] {
+		kind, unit, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			return UnitSystem{}, fmt.Errorf("invalid unit override %q; expected kind=unit", part)
+		}
+		kind, unit = strings.TrimSpace(kind), strings.TrimSpace(unit)
+		if alias, ok := unitAliases[kind]; ok {
+			kind = alias
+		}
+
+		choices, ok := unitChoices[kind]
+		if !ok {
+			return UnitSystem{}, fmt.Errorf("unknown measurement %q; use one of %s", kind, strings.Join(sortedKeys(unitChoices), ", "))
+		}
+		if !slices.Contains(choices, unit) {
+			return UnitSystem{}, fmt.Errorf("unknown %s unit %q; use one of %s", kind, unit, strings.Join(choices, ", "))
+		}
+
+		switch kind {
+		case "temperature":
+			units.TempUnit = unit
+		case "speed":
+			units.SpeedUnit = unit
+		case "pressure":
+			units.PressureUnit = unit
+		case "precipitation":
+			units.PrecipUnit = unit
+		}
+	}
+	return units, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Temp converts a temperature from °C.
+func (u UnitSystem) Temp(c float64) float64 {
+	switch u.TempUnit {
+	case unitFahrenheit:
+		return c*9/5 + 32
+	case unitKelvin:
+		return c + 273.15
+	}
+	return c
+}
+
+// Celsius converts a temperature back to °C; it is the inverse of Temp.
+func (u UnitSystem) Celsius(t float64) float64 {
+	switch u.TempUnit {
+	case unitFahrenheit:
+		return (t - 32) * 5 / 9
+	case unitKelvin:
+		return t - 273.15
+	}
+	return t
+}
+
+// TempDelta converts a temperature difference from °C.
+func (u UnitSystem) TempDelta(c float64) float64 {
+	if u.TempUnit == unitFahrenheit {
+		return c * 9 / 5
+	}
+	return c
+}
+
+// Speed converts a speed from km/h.
+func (u UnitSystem) Speed(kph float64) float64 {
+	switch u.SpeedUnit {
+	case unitMph:
+		return kph / 1.609344
+	case unitMs:
+		return kph / 3.6
+	case unitKnots:
+		return kph / 1.852
+	}
+	return kph
+}
+
+// Pressure converts a pressure from millibars.
+func (u UnitSystem) Pressure(mb float64) float64 {
+	switch u.PressureUnit {
+	case unitKPa:
+		return mb / 10
+	case unitInHg:
+		return mb * 0.02953
+	}
+	return mb
+}
+
+// Precip converts a precipitation amount from millimetres.
+func (u UnitSystem) Precip(mm float64) float64 {
+	if u.PrecipUnit == unitInch {
+		return mm / 25.4
+	}
+	return mm
+}
+
+func (u UnitSystem) TempSymbol() string {
+	switch u.TempUnit {
+	case unitFahrenheit:
+		return "°F"
+	case unitKelvin:
+		return "K"
+	}
+	return "°C"
+}
+
+func (u UnitSystem) SpeedSymbol() string {
+	switch u.SpeedUnit {
+	case unitMph:
+		return "mph"
+	case unitMs:
+		return "m/s"
+	case unitKnots:
+		return "kn"
+	}
+	return "km/h"
+}
+
+func (u UnitSystem) PressureSymbol() string {
+	switch u.PressureUnit {
+	case unitKPa:
+		return "kPa"
+	case unitInHg:
+		return "inHg"
+	}
+	return "hPa"
+}
+
+func (u UnitSystem) PrecipSymbol() string {
+	if u.PrecipUnit == unitInch {
+		return "in"
+	}
+	return "mm"
+}
+
+// FormatTemp formats a temperature given in °C, e.g. "21.5°C" or "70.7°F".
+func (u UnitSystem) FormatTemp(c float64) string {
+	if u.TempUnit == unitKelvin {
+		return fmt.Sprintf("%.1f K", u.Temp(c))
+	}
+	return fmt.Sprintf("%.1f%s", u.Temp(c), u.TempSymbol())
+}
+
+// FormatTempDelta formats a temperature difference given in °C.
+func (u UnitSystem) FormatTempDelta(c float64) string {
+	if u.TempUnit == unitKelvin {
+		return fmt.Sprintf("%.1f K", u.TempDelta(c))
+	}
+	return fmt.Sprintf("%.1f%s", u.TempDelta(c), u.TempSymbol())
+}
+
+// FormatSpeed formats a speed given in km/h.
+func (u UnitSystem) FormatSpeed(kph float64) string {
+	return fmt.Sprintf("%.1f %s", u.Speed(kph), u.SpeedSymbol())
+}
+
+// FormatPressure formats a pressure given in millibars.
+func (u UnitSystem) FormatPressure(mb float64) string {
+	if u.PressureUnit == unitInHg {
+		return fmt.Sprintf("%.2f %s", u.Pressure(mb), u.PressureSymbol())
+	}
+	return fmt.Sprintf("%.1f %s", u.Pressure(mb), u.PressureSymbol())
+}
+
+// FormatPrecip formats a precipitation amount given in millimetres.
+func (u UnitSystem) FormatPrecip(mm float64) string {
+	if u.PrecipUnit == unitInch {
+		return fmt.Sprintf("%.2f %s", u.Precip(mm), u.PrecipSymbol())
+	}
+	return fmt.Sprintf("%.1f %s", u.Precip(mm), u.PrecipSymbol())
+}
@@ -6,9 +6,9 @@ import (
 	"strings"
 )
 
-func generateVisualization(data []WeatherData) {
+func generateVisualization(data []WeatherData, units UnitSystem) {
 	fmt.Println("\n=== TEMPERATURE VISUALIZATION ===")
-	
+
 	if len(data) == 0 {
 		fmt.Println("No data to visualize")
 		return
@@ -23,18 +23,18 @@ func generateVisualization(data []WeatherData) {
 
 	fmt.Println("\nTemperature Chart:")
 	fmt.Println("=================")
-	
+
 	for _, wd := range data {
 		// Scale temperature to bar length (0-50 characters)
-		barLength := int(((wd.Temp - minTemp) / tempRange) * 50)
+		barLength := int(((wd.TempC - minTemp) / tempRange) * 50)
 		if barLength < 1 {
 			barLength = 1
 		}
-		
+
 		bar := strings.Repeat("█", barLength)
 		empty := strings.Repeat(" ", 50-barLength)
-		
-		fmt.Printf("%-15s |%s%s| %.1f°C\n", wd.City, bar, empty, wd.Temp)
+
+		fmt.Printf("%-15s |%s%s| %s\n", wd.Location.Name, bar, empty, units.FormatTemp(wd.TempC))
 	}
 
 	// Add temperature scale
@@ -42,28 +42,28 @@ func generateVisualization(data []WeatherData) {
 	scaleStep := tempRange / 5
 	for i := 0; i <= 5; i++ {
 		temp := minTemp + (scaleStep * float64(i))
-		fmt.Printf("%.1f°C ", temp)
+		fmt.Printf("%s ", units.FormatTemp(temp))
 	}
 	fmt.Println()
 
 	// Temperature comparison
 	fmt.Println("\nTemperature Differences:")
-	reference := data[0].Temp
+	reference := data[0].TempC
 	for i := 1; i < len(data); i++ {
-		diff := data[i].Temp - reference
+		diff := data[i].TempC - reference
 		comparison := "colder than"
 		if diff > 0 {
 			comparison = "warmer than"
 		}
-		fmt.Printf("%s is %.1f°C %s %s\n", 
-			data[i].City, math.Abs(diff), comparison, data[0].City)
+		fmt.Printf("%s is %s %s %s\n",
+			data[i].Location.Name, units.FormatTempDelta(math.Abs(diff)), comparison, data[0].Location.Name)
 	}
 }
 
 func getTemperatures(data []WeatherData) []float64 {
 	var temps []float64
 	for _, wd := range data {
-		temps = append(temps, wd.Temp)
+		temps = append(temps, wd.TempC)
 	}
 	return temps
-}
\ No newline at end of file
+}